@@ -1,10 +1,20 @@
 package main
 
 import (
+	"time"
+
 	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/batching"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/cache"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/chunking"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/coalescing"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/jobs"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/morphology"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/segmentation"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/service"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/tagset"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/tokenizer"
 	"github.com/labstack/gommon/color"
 
 	"github.com/pkg/errors"
@@ -15,16 +25,112 @@ func main() {
 
 	data := service.Data{}
 	data.Port = goapp.Config.GetInt("port")
-	var err error
-	data.Segmenter, err = segmentation.NewClient(goapp.Config.GetString("segmentation.url"))
+	data.BatchWorkers = goapp.Config.GetInt("batch.workers")
+	data.BatchMaxItems = goapp.Config.GetInt("batch.maxItems")
+	data.BatchMaxLen = goapp.Config.GetInt("batch.maxLen")
+	data.BatchJoinLen = goapp.Config.GetInt("batch.joinLen")
+	budget, err := initRetryBudget()
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "Can't init retry budget"))
+	}
+	data.Segmenter, err = initSegmenter(budget)
 	if err != nil {
 		goapp.Log.Fatal(errors.Wrap(err, "Can't init segmenter"))
 	}
+	goapp.Config.SetDefault("segmentation.fallback", true)
+	if _, ok := data.Segmenter.(*tokenizer.Segmenter); !ok && goapp.Config.GetBool("segmentation.fallback") {
+		data.FallbackSegmenter = tokenizer.NewSegmenter()
+		goapp.Log.Info("Using local segmenter if lex fails")
+	}
 
-	data.Tagger, err = morphology.NewClient(goapp.Config.GetString("morphology.url"))
+	if b, ok := data.Segmenter.(service.Backend); ok {
+		data.Backends = append(data.Backends, b)
+	}
+
+	mcfg, err := backendConfig(morphology.DefaultConfig(), "morphology", budget)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "Can't init tagger config"))
+	}
+	mc, err := morphology.NewClient(mcfg)
 	if err != nil {
 		goapp.Log.Fatal(errors.Wrap(err, "Can't init tagger"))
 	}
+	data.Tagger = mc
+	data.Backends = append(data.Backends, mc)
+
+	goapp.Config.SetDefault("batching.maxItems", 20)
+	goapp.Config.SetDefault("batching.maxLen", 2000)
+	if window := goapp.Config.GetDuration("batching.window"); window > 0 {
+		maxItems, maxLen := goapp.Config.GetInt("batching.maxItems"), goapp.Config.GetInt("batching.maxLen")
+		data.Segmenter, err = batching.NewSegmenter(data.Segmenter, window, maxItems, maxLen)
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "Can't init batching segmenter"))
+		}
+		data.Tagger, err = batching.NewTagger(data.Tagger, window, maxItems, maxLen)
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "Can't init batching tagger"))
+		}
+		goapp.Log.Infof("Batching texts for %v", window)
+	}
+
+	goapp.Config.SetDefault("chunking.size", 10000)
+	goapp.Config.SetDefault("chunking.segmenterWorkers", 1)
+	goapp.Config.SetDefault("chunking.taggerWorkers", 4)
+	if size := goapp.Config.GetInt("chunking.size"); size > 0 {
+		data.Segmenter, err = chunking.NewSegmenter(data.Segmenter, size, goapp.Config.GetInt("chunking.segmenterWorkers"))
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "Can't init chunking segmenter"))
+		}
+		data.Tagger, err = chunking.NewTagger(data.Tagger, size, goapp.Config.GetInt("chunking.taggerWorkers"))
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "Can't init chunking tagger"))
+		}
+		goapp.Log.Infof("Chunking texts longer than %d", size)
+	}
+
+	goapp.Config.SetDefault("coalescing.enabled", true)
+	if goapp.Config.GetBool("coalescing.enabled") {
+		data.Segmenter, err = coalescing.NewSegmenter(data.Segmenter)
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "Can't init coalescing segmenter"))
+		}
+		data.Tagger, err = coalescing.NewTagger(data.Tagger)
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "Can't init coalescing tagger"))
+		}
+		goapp.Log.Info("Coalescing the same texts")
+	}
+
+	dc, err := initCache(&data)
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "Can't init cache"))
+	}
+	if dc != nil {
+		defer dc.Close()
+	}
+
+	goapp.Config.SetDefault("jobs.workers", 2)
+	goapp.Config.SetDefault("jobs.max", 100)
+	goapp.Config.SetDefault("jobs.ttl", "10m")
+	jm, err := jobs.NewManager(goapp.Config.GetInt("jobs.workers"), goapp.Config.GetInt("jobs.max"),
+		goapp.Config.GetDuration("jobs.ttl"))
+	if err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "Can't init job manager"))
+	}
+	defer jm.Close()
+	data.Jobs = jm
+
+	if data.Priorities, err = initPriorities(); err != nil {
+		goapp.Log.Fatal(errors.Wrap(err, "Can't init priorities"))
+	}
+
+	if f := goapp.Config.GetString("tagset.file"); f != "" {
+		data.Tagset, err = tagset.Load(f)
+		if err != nil {
+			goapp.Log.Fatal(errors.Wrap(err, "Can't init tagset"))
+		}
+		goapp.Log.Infof("Loaded tagset from %s", f)
+	}
 
 	printBanner()
 
@@ -34,6 +140,171 @@ func main() {
 	}
 }
 
+//segmentationType returns the segmentation.type, lex by default
+func segmentationType() string {
+	if t := goapp.Config.GetString("segmentation.type"); t != "" {
+		return t
+	}
+	return "lex"
+}
+
+//initSegmenter creates the lex client or the local segmenter by segmentation.type
+func initSegmenter(budget *backend.Budget) (service.Segmenter, error) {
+	switch t := segmentationType(); t {
+	case "lex":
+		cfg, err := backendConfig(segmentation.DefaultConfig(), "segmentation", budget)
+		if err != nil {
+			return nil, err
+		}
+		return segmentation.NewClient(cfg)
+	case "local":
+		goapp.Log.Info("Using local segmenter")
+		return tokenizer.NewSegmenter(), nil
+	default:
+		return nil, errors.Errorf("wrong segmentation type '%s'", t)
+	}
+}
+
+//backendConfig reads the client config from the key section:
+//url - comma separated URLs of replicas, maxFails - failures before ejecting the URL, probeInterval,
+//breaker.failures - failures before opening the circuit breaker (0 disables it), breaker.openTimeout, breaker.halfOpenCalls,
+//retry.backoff - comma separated delays, retry.codes - comma separated HTTP codes, retry.errors, retry.maxAttempts,
+//limit.initial, limit.min, limit.max, limit.decrease, limit.latency - the adaptive limit of parallel calls,
+//limit.queue - the max count of waiting calls
+func backendConfig(res backend.Config, key string, budget *backend.Budget) (backend.Config, error) {
+	res.URLs = backend.ParseURLs(goapp.Config.GetString(key + ".url"))
+	res.Budget = budget
+	if v := goapp.Config.GetInt(key + ".maxFails"); v > 0 {
+		res.MaxFails = v
+	}
+	if v := goapp.Config.GetDuration(key + ".probeInterval"); v > 0 {
+		res.ProbeInterval = v
+	}
+	if goapp.Config.IsSet(key + ".breaker.failures") {
+		res.BreakerFailures = goapp.Config.GetInt(key + ".breaker.failures")
+	}
+	if v := goapp.Config.GetDuration(key + ".breaker.openTimeout"); v > 0 {
+		res.BreakerOpenTimeout = v
+	}
+	if v := goapp.Config.GetInt(key + ".breaker.halfOpenCalls"); v > 0 {
+		res.BreakerHalfOpenCalls = v
+	}
+	var err error
+	if goapp.Config.IsSet(key + ".retry.backoff") {
+		if res.Backoff, err = backend.ParseDurations(goapp.Config.GetStringSlice(key + ".retry.backoff")...); err != nil {
+			return res, err
+		}
+	}
+	if goapp.Config.IsSet(key + ".retry.codes") {
+		if res.RetryCodes, err = backend.ParseCodes(goapp.Config.GetStringSlice(key + ".retry.codes")...); err != nil {
+			return res, err
+		}
+	}
+	if goapp.Config.IsSet(key + ".retry.errors") {
+		res.RetryErrors = goapp.Config.GetBool(key + ".retry.errors")
+	}
+	if v := goapp.Config.GetInt(key + ".retry.maxAttempts"); v > 0 {
+		res.MaxAttempts = v
+	}
+	if v := goapp.Config.GetInt(key + ".limit.initial"); v > 0 {
+		res.LimitInitial = v
+	}
+	if v := goapp.Config.GetInt(key + ".limit.min"); v > 0 {
+		res.LimitMin = v
+	}
+	if v := goapp.Config.GetInt(key + ".limit.max"); v > 0 {
+		res.LimitMax = v
+	}
+	if v := goapp.Config.GetFloat64(key + ".limit.decrease"); v > 0 {
+		res.LimitDecrease = v
+	}
+	if goapp.Config.IsSet(key + ".limit.latency") {
+		res.LimitLatency = goapp.Config.GetDuration(key + ".limit.latency")
+	}
+	if v := goapp.Config.GetInt(key + ".limit.queue"); v > 0 {
+		res.QueueSize = v
+	}
+	return res, nil
+}
+
+//initRetryBudget creates the retry budget shared by lex and morph, returns nil if retryBudget.enabled is false
+func initRetryBudget() (*backend.Budget, error) {
+	goapp.Config.SetDefault("retryBudget.enabled", true)
+	goapp.Config.SetDefault("retryBudget.percent", 20)
+	goapp.Config.SetDefault("retryBudget.minRetries", 10)
+	goapp.Config.SetDefault("retryBudget.window", "10s")
+	if !goapp.Config.GetBool("retryBudget.enabled") {
+		return nil, nil
+	}
+	res, err := backend.NewBudget(goapp.Config.GetFloat64("retryBudget.percent"), goapp.Config.GetInt("retryBudget.minRetries"),
+		goapp.Config.GetDuration("retryBudget.window"))
+	if err != nil {
+		return nil, err
+	}
+	goapp.Log.Infof("Retry budget: %v%% of requests, min %d retries in %v", goapp.Config.GetFloat64("retryBudget.percent"),
+		goapp.Config.GetInt("retryBudget.minRetries"), goapp.Config.GetDuration("retryBudget.window"))
+	return res, nil
+}
+
+//initPriorities reads priority.default, priority.trustHeader and priority.keys - the map of API keys to priorities
+func initPriorities() (*service.Priorities, error) {
+	goapp.Config.SetDefault("priority.default", "normal")
+	goapp.Config.SetDefault("priority.trustHeader", true)
+	res := &service.Priorities{TrustHeader: goapp.Config.GetBool("priority.trustHeader"),
+		Keys: map[string]backend.Priority{}}
+	var err error
+	if res.Default, err = backend.ParsePriority(goapp.Config.GetString("priority.default")); err != nil {
+		return nil, err
+	}
+	for k, v := range goapp.Config.GetStringMapString("priority.keys") {
+		if res.Keys[k], err = backend.ParsePriority(v); err != nil {
+			return nil, err
+		}
+	}
+	goapp.Log.Infof("Priority: default %s, trust header %t, %d API keys", res.Default, res.TrustHeader, len(res.Keys))
+	return res, nil
+}
+
+//initCache sets the memory and disk caches, returns the disk cache to be closed.
+//The disk cache is opened in the background as the old process keeps it locked on a graceful restart
+func initCache(data *service.Data) (*cache.LazyDisk, error) {
+	goapp.Config.SetDefault("cache.size", 1000)
+	goapp.Config.SetDefault("cache.maxMB", 100)
+	goapp.Config.SetDefault("cache.disk.ttl", "168h")
+	goapp.Config.SetDefault("cache.disk.maxItems", 100000)
+	var caches []cache.Store
+	if size := goapp.Config.GetInt("cache.size"); size > 0 {
+		maxMB := goapp.Config.GetInt("cache.maxMB")
+		m, err := cache.NewLRU(size, maxMB*1024*1024)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, m)
+		goapp.Log.Infof("Caching %d texts (%d MB) in memory", size, maxMB)
+	}
+	var dc *cache.LazyDisk
+	if f := goapp.Config.GetString("cache.disk.file"); f != "" {
+		var err error
+		// the cached results of lex and the local segmenter differ
+		v := goapp.Config.GetString("cache.disk.version") + ":" + segmentationType() + ":" + segmentation.RulesVersion
+		dc, err = cache.NewLazyDisk(f, v, goapp.Config.GetDuration("cache.disk.ttl"), goapp.Config.GetInt("cache.disk.maxItems"),
+			time.Second)
+		if err != nil {
+			return nil, err
+		}
+		caches = append(caches, dc)
+		goapp.Log.Infof("Caching texts in %s, version '%s'", f, v)
+	}
+	switch len(caches) {
+	case 0:
+	case 1:
+		data.Cache = caches[0]
+	default:
+		data.Cache = cache.NewChain(caches...)
+	}
+	return dc, nil
+}
+
 var (
 	version string
 )
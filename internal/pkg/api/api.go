@@ -12,3 +12,9 @@ type TaggerResult struct {
 	Msd  [][][]string `json:"msd"`
 	Stem []string     `json:"stem"`
 }
+
+//Analysis is the segmentation and tagging result of a text
+type Analysis struct {
+	Segments *SegmenterResult `json:"segments"`
+	Tags     *TaggerResult    `json:"tags"`
+}
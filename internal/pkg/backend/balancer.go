@@ -0,0 +1,161 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+)
+
+//Endpoint is one backend replica
+type Endpoint struct {
+	URL string
+
+	outstanding int
+	fails       int
+	ejected     bool
+}
+
+//Balancer selects the endpoint with the least outstanding requests.
+//An endpoint is ejected after MaxFails failures in a row and is re-admitted after a successful probe
+type Balancer struct {
+	name      string
+	maxFails  int
+	endpoints []*Endpoint
+	probe     func(url string) bool
+
+	lock sync.Mutex
+	next int
+
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+//NewBalancer creates the balancer and starts the probes of ejected endpoints
+func NewBalancer(cfg Config) (*Balancer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	res := &Balancer{name: cfg.Name, maxFails: cfg.MaxFails, closeC: make(chan struct{})}
+	for _, u := range cfg.URLs {
+		res.endpoints = append(res.endpoints, &Endpoint{URL: u})
+		endpointUp.WithLabelValues(cfg.Name, u).Set(1)
+	}
+	hc := &http.Client{Timeout: 2 * time.Second}
+	res.probe = func(url string) bool { return probe(hc, url) }
+	res.wg.Add(1)
+	go res.runProber(cfg.ProbeInterval)
+	return res, nil
+}
+
+//Get returns the endpoint for a call. Done must be called after the call.
+//All endpoints are used if all of them are ejected
+func (b *Balancer) Get() *Endpoint {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var res *Endpoint
+	l := len(b.endpoints)
+	for i := 0; i < l; i++ {
+		e := b.endpoints[(b.next+i)%l]
+		if e.ejected {
+			continue
+		}
+		if res == nil || e.outstanding < res.outstanding {
+			res = e
+		}
+	}
+	if res == nil {
+		res = b.endpoints[b.next%l]
+	}
+	b.next = (b.next + 1) % l
+	res.outstanding++
+	outstanding.WithLabelValues(b.name, res.URL).Inc()
+	return res
+}
+
+//Done marks the end of the call. CallFailed is for connection errors and 5xx responses, CallIgnored is not counted
+func (b *Balancer) Done(e *Endpoint, res CallResult) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	e.outstanding--
+	outstanding.WithLabelValues(b.name, e.URL).Dec()
+	if res == CallIgnored {
+		return
+	}
+	if res == CallOK {
+		e.fails = 0
+		totalRequests.WithLabelValues(b.name, e.URL, "ok").Inc()
+		return
+	}
+	totalRequests.WithLabelValues(b.name, e.URL, "fail").Inc()
+	e.fails++
+	if e.fails >= b.maxFails && !e.ejected {
+		goapp.Log.Warnf("Ejecting %s endpoint %s after %d failures", b.name, e.URL, e.fails)
+		e.ejected = true
+		endpointUp.WithLabelValues(b.name, e.URL).Set(0)
+	}
+}
+
+//Close stops the probes
+func (b *Balancer) Close() {
+	close(b.closeC)
+	b.wg.Wait()
+}
+
+func (b *Balancer) runProber(interval time.Duration) {
+	defer b.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.closeC:
+			return
+		case <-ticker.C:
+			b.probeEjected()
+		}
+	}
+}
+
+func (b *Balancer) probeEjected() {
+	for _, e := range b.ejectedEndpoints() {
+		if b.probe(e.URL) {
+			b.lock.Lock()
+			goapp.Log.Infof("Re-admitting %s endpoint %s", b.name, e.URL)
+			e.ejected, e.fails = false, 0
+			endpointUp.WithLabelValues(b.name, e.URL).Set(1)
+			b.lock.Unlock()
+		}
+	}
+}
+
+func (b *Balancer) ejectedEndpoints() []*Endpoint {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	var res []*Endpoint
+	for _, e := range b.endpoints {
+		if e.ejected {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+//probe checks if the endpoint responds with a non 5xx code. lex and morph accept POST only, so any 4xx is fine
+func probe(hc *http.Client, url string) bool {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 10000))
+	_ = resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
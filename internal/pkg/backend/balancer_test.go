@@ -0,0 +1,173 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBalancer_Fail(t *testing.T) {
+	_, err := NewBalancer(DefaultConfig("lex"))
+	assert.NotNil(t, err)
+	_, err = NewBalancer(DefaultConfig("lex", "u1", ""))
+	assert.NotNil(t, err)
+	cfg := DefaultConfig("lex", "u1")
+	cfg.MaxFails = 0
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+	cfg = DefaultConfig("lex", "u1")
+	cfg.ProbeInterval = 0
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+	cfg = DefaultConfig("lex", "u1")
+	cfg.BreakerFailures = -1
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+	cfg = DefaultConfig("lex", "u1")
+	cfg.BreakerOpenTimeout = 0
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+	cfg = DefaultConfig("lex", "u1")
+	cfg.BreakerHalfOpenCalls = 0
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+	cfg = DefaultConfig("lex", "u1")
+	cfg.MaxAttempts = 0
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+	cfg = DefaultConfig("lex", "u1")
+	cfg.Backoff = []time.Duration{-time.Second}
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+	cfg = DefaultConfig("lex", "u1")
+	cfg.RetryCodes = []int{1000}
+	_, err = NewBalancer(cfg)
+	assert.NotNil(t, err)
+}
+
+func TestBalancer_RoundRobin(t *testing.T) {
+	b := newTestBalancer(t, "u1", "u2", "u3")
+	var urls []string
+	for i := 0; i < 6; i++ {
+		e := b.Get()
+		urls = append(urls, e.URL)
+		b.Done(e, CallOK)
+	}
+	assert.Equal(t, []string{"u1", "u2", "u3", "u1", "u2", "u3"}, urls)
+}
+
+func TestBalancer_LeastOutstanding(t *testing.T) {
+	b := newTestBalancer(t, "u1", "u2")
+	e1 := b.Get()
+	assert.Equal(t, "u1", e1.URL)
+	assert.Equal(t, "u2", b.Get().URL)
+	assert.Equal(t, "u1", b.Get().URL)
+	b.Done(e1, CallOK)
+	b.Done(e1, CallOK)
+	assert.Equal(t, "u1", b.Get().URL)
+}
+
+func TestBalancer_Ejects(t *testing.T) {
+	b := newTestBalancer(t, "u1", "u2")
+	e1 := b.endpoints[0]
+	for i := 0; i < 3; i++ {
+		b.begin(e1)
+		b.Done(e1, CallFailed)
+	}
+	assert.True(t, e1.ejected)
+	for i := 0; i < 3; i++ {
+		e := b.Get()
+		assert.Equal(t, "u2", e.URL)
+		b.Done(e, CallOK)
+	}
+}
+
+func TestBalancer_IgnoredNotCounted(t *testing.T) {
+	b := newTestBalancer(t, "u1", "u2")
+	e1 := b.endpoints[0]
+	for i := 0; i < 2; i++ {
+		b.begin(e1)
+		b.Done(e1, CallFailed)
+	}
+	b.begin(e1)
+	b.Done(e1, CallIgnored)
+	assert.Equal(t, 2, e1.fails)
+	assert.Equal(t, 0, e1.outstanding)
+	assert.False(t, e1.ejected)
+}
+
+func TestBalancer_AllEjected(t *testing.T) {
+	b := newTestBalancer(t, "u1")
+	for i := 0; i < 3; i++ {
+		b.Done(b.Get(), CallFailed)
+	}
+	assert.True(t, b.endpoints[0].ejected)
+	assert.Equal(t, "u1", b.Get().URL)
+}
+
+func TestBalancer_Readmits(t *testing.T) {
+	b := newTestBalancer(t, "u1", "u2")
+	var probes int32
+	b.probe = func(url string) bool {
+		atomic.AddInt32(&probes, 1)
+		return url == "u1"
+	}
+	for _, e := range b.endpoints {
+		for i := 0; i < 3; i++ {
+			b.begin(e)
+			b.Done(e, CallFailed)
+		}
+	}
+	b.probeEjected()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&probes))
+	assert.False(t, b.endpoints[0].ejected)
+	assert.Equal(t, 0, b.endpoints[0].fails)
+	assert.True(t, b.endpoints[1].ejected)
+}
+
+func TestBalancer_SuccessResetsFails(t *testing.T) {
+	b := newTestBalancer(t, "u1")
+	b.Done(b.Get(), CallFailed)
+	b.Done(b.Get(), CallFailed)
+	b.Done(b.Get(), CallOK)
+	b.Done(b.Get(), CallFailed)
+	assert.False(t, b.endpoints[0].ejected)
+}
+
+func TestProbe(t *testing.T) {
+	code := int32(http.StatusMethodNotAllowed)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(int(atomic.LoadInt32(&code)))
+	}))
+	defer server.Close()
+	hc := &http.Client{Timeout: time.Second}
+	assert.True(t, probe(hc, server.URL))
+	atomic.StoreInt32(&code, http.StatusBadGateway)
+	assert.False(t, probe(hc, server.URL))
+	assert.False(t, probe(hc, "http://127.0.0.1:1"))
+}
+
+func TestParseURLs(t *testing.T) {
+	assert.Equal(t, []string{"u1", "u2", "u3"}, ParseURLs("u1, u2,", "u3"))
+	assert.Equal(t, []string{}, ParseURLs(""))
+}
+
+func newTestBalancer(t *testing.T, urls ...string) *Balancer {
+	t.Helper()
+	b, err := NewBalancer(DefaultConfig("lex", urls...))
+	require.Nil(t, err)
+	t.Cleanup(b.Close)
+	return b
+}
+
+//begin simulates a running call to the endpoint
+func (b *Balancer) begin(e *Endpoint) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	e.outstanding++
+}
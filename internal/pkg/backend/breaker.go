@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+)
+
+//BreakerState is the state of the circuit breaker
+type BreakerState int
+
+const (
+	//StateClosed - calls are allowed
+	StateClosed BreakerState = iota
+	//StateOpen - calls are rejected with utils.ErrBackendUnavailable
+	StateOpen
+	//StateHalfOpen - a few trial calls are allowed to check if the backend is back
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+//Breaker is a circuit breaker of one backend. It opens after BreakerFailures failed calls in a row,
+//rejects calls for BreakerOpenTimeout and then lets BreakerHalfOpenCalls trial calls through.
+//The breaker closes if all trial calls succeed and opens again on a failure
+type Breaker struct {
+	name          string
+	maxFails      int
+	openTimeout   time.Duration
+	halfOpenCalls int
+	now           func() time.Time
+
+	lock      sync.Mutex
+	state     BreakerState
+	fails     int
+	trials    int
+	successes int
+	openedAt  time.Time
+}
+
+//NewBreaker creates the breaker, the breaker is disabled if cfg.BreakerFailures is 0
+func NewBreaker(cfg Config) *Breaker {
+	res := &Breaker{name: cfg.Name, maxFails: cfg.BreakerFailures, openTimeout: cfg.BreakerOpenTimeout,
+		halfOpenCalls: cfg.BreakerHalfOpenCalls, now: time.Now}
+	breakerState.WithLabelValues(cfg.Name).Set(float64(StateClosed))
+	return res
+}
+
+//Allow checks if the call can be made, returns utils.ErrBackendUnavailable if not. Done must be called after an allowed call
+func (b *Breaker) Allow() error {
+	if b.maxFails == 0 {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.setState(StateHalfOpen)
+	}
+	if b.state == StateOpen || (b.state == StateHalfOpen && b.trials >= b.halfOpenCalls) {
+		totalBreakerRejected.WithLabelValues(b.name).Inc()
+		return utils.ErrBackendUnavailable
+	}
+	if b.state == StateHalfOpen {
+		b.trials++
+	}
+	return nil
+}
+
+//Done records the result of the call. CallFailed is for connection errors and 5xx responses.
+//A CallIgnored call is not counted, its half-open trial slot is freed
+func (b *Breaker) Done(res CallResult) {
+	if b.maxFails == 0 {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	failed := res == CallFailed
+	if res == CallIgnored {
+		if b.state == StateHalfOpen && b.trials > 0 {
+			b.trials--
+		}
+		return
+	}
+	switch b.state {
+	case StateClosed:
+		if !failed {
+			b.fails = 0
+			return
+		}
+		b.fails++
+		if b.fails >= b.maxFails {
+			goapp.Log.Warnf("Opening %s breaker after %d failures", b.name, b.fails)
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if failed {
+			goapp.Log.Warnf("Opening %s breaker again, the trial call failed", b.name)
+			b.setState(StateOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.halfOpenCalls {
+			goapp.Log.Infof("Closing %s breaker", b.name)
+			b.setState(StateClosed)
+		}
+	}
+}
+
+//State returns the current state
+func (b *Breaker) State() BreakerState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state
+}
+
+func (b *Breaker) setState(s BreakerState) {
+	b.state = s
+	b.fails, b.trials, b.successes = 0, 0, 0
+	if s == StateOpen {
+		b.openedAt = b.now()
+	}
+	breakerState.WithLabelValues(b.name).Set(float64(s))
+}
@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker_Opens(t *testing.T) {
+	b, _ := newTestBreaker()
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, b.Allow())
+		b.Done(CallFailed)
+	}
+	assert.Equal(t, StateClosed, b.State())
+	assert.Nil(t, b.Allow())
+	b.Done(CallFailed)
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, utils.ErrBackendUnavailable, b.Allow())
+}
+
+func TestBreaker_SuccessResetsFails(t *testing.T) {
+	b, _ := newTestBreaker()
+	for i := 0; i < 4; i++ {
+		b.Done(CallFailed)
+	}
+	b.Done(CallOK)
+	for i := 0; i < 4; i++ {
+		b.Done(CallFailed)
+	}
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_HalfOpen(t *testing.T) {
+	b, now := newTestBreaker()
+	open(b)
+	*now = now.Add(9 * time.Second)
+	assert.Equal(t, utils.ErrBackendUnavailable, b.Allow())
+	*now = now.Add(time.Second)
+	assert.Nil(t, b.Allow())
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.Equal(t, utils.ErrBackendUnavailable, b.Allow())
+	b.Done(CallOK)
+	assert.Equal(t, StateClosed, b.State())
+	assert.Nil(t, b.Allow())
+}
+
+func TestBreaker_HalfOpenFails(t *testing.T) {
+	b, now := newTestBreaker()
+	open(b)
+	*now = now.Add(10 * time.Second)
+	assert.Nil(t, b.Allow())
+	b.Done(CallFailed)
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, utils.ErrBackendUnavailable, b.Allow())
+}
+
+func TestBreaker_HalfOpenCanceled(t *testing.T) {
+	b, now := newTestBreaker()
+	open(b)
+	*now = now.Add(10 * time.Second)
+	assert.Nil(t, b.Allow())
+	b.Done(CallIgnored)
+	assert.Equal(t, StateHalfOpen, b.State())
+	assert.Nil(t, b.Allow())
+	b.Done(CallOK)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_IgnoredNotCounted(t *testing.T) {
+	b, _ := newTestBreaker()
+	for i := 0; i < 4; i++ {
+		b.Done(CallFailed)
+	}
+	b.Done(CallIgnored)
+	assert.Equal(t, StateClosed, b.State())
+	b.Done(CallFailed)
+	assert.Equal(t, StateOpen, b.State())
+}
+
+func TestBreaker_HalfOpenCalls(t *testing.T) {
+	cfg := DefaultConfig("lex", "u1")
+	cfg.BreakerHalfOpenCalls = 2
+	b := NewBreaker(cfg)
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	open(b)
+	now = now.Add(10 * time.Second)
+	assert.Nil(t, b.Allow())
+	assert.Nil(t, b.Allow())
+	assert.Equal(t, utils.ErrBackendUnavailable, b.Allow())
+	b.Done(CallOK)
+	assert.Equal(t, StateHalfOpen, b.State())
+	b.Done(CallOK)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_Disabled(t *testing.T) {
+	cfg := DefaultConfig("lex", "u1")
+	cfg.BreakerFailures = 0
+	b := NewBreaker(cfg)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, b.Allow())
+		b.Done(CallFailed)
+	}
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreakerState_String(t *testing.T) {
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "half-open", StateHalfOpen.String())
+}
+
+func TestNewStatus(t *testing.T) {
+	b := newTestBalancer(t, "u1", "u2")
+	br, _ := newTestBreaker()
+	e := b.Get()
+	open(br)
+	l := NewLimiter(DefaultConfig("lex", "u1"))
+	assert.Equal(t, Status{Name: "lex", Breaker: "open", Limit: 10, Endpoints: []EndpointStatus{{URL: "u1", Up: true, Outstanding: 1},
+		{URL: "u2", Up: true}}}, NewStatus(b, br, l))
+	b.Done(e, CallOK)
+}
+
+func newTestBreaker() (*Breaker, *time.Time) {
+	b := NewBreaker(DefaultConfig("lex", "u1"))
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+//open fails the calls until the breaker opens
+func open(b *Breaker) {
+	for b.State() != StateOpen {
+		b.Done(CallFailed)
+	}
+}
@@ -0,0 +1,83 @@
+package backend
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const budgetBuckets = 10
+
+//Budget limits the retries of all backends to a percent of the requests made in the time window,
+//at least minRetries retries are allowed in the window. A nil budget allows all retries
+type Budget struct {
+	percent    float64
+	minRetries int
+	bucketDur  time.Duration
+	now        func() time.Time
+
+	lock    sync.Mutex
+	buckets [budgetBuckets]budgetBucket
+}
+
+type budgetBucket struct {
+	at       int64
+	requests int
+	retries  int
+}
+
+//NewBudget creates the retry budget
+func NewBudget(percent float64, minRetries int, window time.Duration) (*Budget, error) {
+	if percent < 0 {
+		return nil, errors.Errorf("wrong retry budget percent %v", percent)
+	}
+	if minRetries < 0 {
+		return nil, errors.Errorf("wrong retry budget min retries %d", minRetries)
+	}
+	if window < budgetBuckets*time.Millisecond {
+		return nil, errors.Errorf("wrong retry budget window %v", window)
+	}
+	return &Budget{percent: percent, minRetries: minRetries, bucketDur: window / budgetBuckets, now: time.Now}, nil
+}
+
+//request records a new call
+func (b *Budget) request() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.current().requests++
+}
+
+//allowRetry checks and records a retry
+func (b *Budget) allowRetry() bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	cur := b.current()
+	requests, retries := 0, 0
+	for _, bk := range b.buckets {
+		if bk.at > cur.at-budgetBuckets {
+			requests += bk.requests
+			retries += bk.retries
+		}
+	}
+	if retries >= b.minRetries && float64(retries) >= float64(requests)*b.percent/100 {
+		return false
+	}
+	cur.retries++
+	return true
+}
+
+func (b *Budget) current() *budgetBucket {
+	at := b.now().UnixNano() / int64(b.bucketDur)
+	res := &b.buckets[at%budgetBuckets]
+	if res.at != at {
+		*res = budgetBucket{at: at}
+	}
+	return res
+}
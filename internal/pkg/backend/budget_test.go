@@ -0,0 +1,72 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBudget_Fail(t *testing.T) {
+	_, err := NewBudget(-1, 10, time.Second)
+	assert.NotNil(t, err)
+	_, err = NewBudget(10, -1, time.Second)
+	assert.NotNil(t, err)
+	_, err = NewBudget(10, 10, time.Millisecond)
+	assert.NotNil(t, err)
+}
+
+func TestBudget_MinRetries(t *testing.T) {
+	b, now := newTestBudget(10, 3)
+	for i := 0; i < 3; i++ {
+		assert.True(t, b.allowRetry())
+	}
+	assert.False(t, b.allowRetry())
+	*now = now.Add(10 * time.Second)
+	assert.True(t, b.allowRetry())
+}
+
+func TestBudget_Percent(t *testing.T) {
+	b, _ := newTestBudget(10, 0)
+	assert.False(t, b.allowRetry())
+	for i := 0; i < 20; i++ {
+		b.request()
+	}
+	assert.True(t, b.allowRetry())
+	assert.True(t, b.allowRetry())
+	assert.False(t, b.allowRetry())
+}
+
+func TestBudget_Window(t *testing.T) {
+	b, now := newTestBudget(10, 0)
+	for i := 0; i < 10; i++ {
+		b.request()
+	}
+	*now = now.Add(5 * time.Second)
+	for i := 0; i < 10; i++ {
+		b.request()
+	}
+	assert.True(t, b.allowRetry())
+	assert.True(t, b.allowRetry())
+	assert.False(t, b.allowRetry())
+	*now = now.Add(5 * time.Second)
+	assert.False(t, b.allowRetry())
+	for i := 0; i < 20; i++ {
+		b.request()
+	}
+	assert.True(t, b.allowRetry())
+	assert.False(t, b.allowRetry())
+}
+
+func TestBudget_Nil(t *testing.T) {
+	var b *Budget
+	b.request()
+	assert.True(t, b.allowRetry())
+}
+
+func newTestBudget(percent float64, minRetries int) (*Budget, *time.Time) {
+	b, _ := NewBudget(percent, minRetries, 10*time.Second)
+	now := time.Unix(1000, 0)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+//Config is the common config of the lex and morph clients
+type Config struct {
+	//Name is the backend name used in metrics and logs
+	Name string
+	//URLs are the backend replicas
+	URLs []string
+	//MaxFails is the count of failures in a row after which the endpoint is ejected
+	MaxFails int
+	//ProbeInterval is the time between the probes of an ejected endpoint
+	ProbeInterval time.Duration
+	//BreakerFailures is the count of failed calls in a row after which the circuit breaker opens, 0 disables the breaker
+	BreakerFailures int
+	//BreakerOpenTimeout is the time the breaker rejects calls before letting trial calls through
+	BreakerOpenTimeout time.Duration
+	//BreakerHalfOpenCalls is the count of successful trial calls required to close the breaker
+	BreakerHalfOpenCalls int
+	//Backoff are the max delays before the retries, the last value is used for the further retries.
+	//The delay is randomized in [0, value)
+	Backoff []time.Duration
+	//RetryCodes are the response codes to retry
+	RetryCodes []int
+	//RetryErrors retries the connection and response decoding errors
+	RetryErrors bool
+	//MaxAttempts is the max count of calls including the first one
+	MaxAttempts int
+	//Budget limits the retries, it may be shared by several backends. No limit if nil
+	Budget *Budget
+	//LimitInitial is the starting limit of parallel calls
+	LimitInitial int
+	//LimitMin, LimitMax are the bounds of the adaptive limit
+	LimitMin, LimitMax int
+	//LimitDecrease is the ratio the limit is multiplied by after a failed or slow call
+	LimitDecrease float64
+	//LimitLatency is the duration after which the call is treated as slow, 0 - latency is not checked
+	LimitLatency time.Duration
+	//QueueSize is the max count of calls waiting for a slot
+	QueueSize int
+}
+
+//DefaultConfig returns the config with the default values
+func DefaultConfig(name string, urls ...string) Config {
+	return Config{Name: name, URLs: urls, MaxFails: 3, ProbeInterval: 5 * time.Second,
+		BreakerFailures: 5, BreakerOpenTimeout: 10 * time.Second, BreakerHalfOpenCalls: 1,
+		Backoff: defaultBackoff(), RetryCodes: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		RetryErrors: true, MaxAttempts: len(utils.ExpBackoffList),
+		LimitInitial: 10, LimitMin: 1, LimitMax: 40, LimitDecrease: 0.5, LimitLatency: 10 * time.Second,
+		QueueSize: 100}
+}
+
+func defaultBackoff() []time.Duration {
+	res := make([]time.Duration, 0, len(utils.ExpBackoffList)-1)
+	for _, v := range utils.ExpBackoffList[1:] {
+		res = append(res, time.Duration(v)*time.Millisecond)
+	}
+	return res
+}
+
+//ParseURLs splits the comma separated URLs, drops empty values
+func ParseURLs(values ...string) []string {
+	return splitList(values...)
+}
+
+//splitList splits the comma separated values, drops empty ones
+func splitList(values ...string) []string {
+	res := make([]string, 0)
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+//ParseDurations parses the comma separated durations, e.g. "40ms,80ms"
+func ParseDurations(values ...string) ([]time.Duration, error) {
+	res := make([]time.Duration, 0)
+	for _, s := range splitList(values...) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "wrong duration '%s'", s)
+		}
+		res = append(res, d)
+	}
+	return res, nil
+}
+
+//ParseCodes parses the comma separated HTTP codes, e.g. "429,503"
+func ParseCodes(values ...string) ([]int, error) {
+	res := make([]int, 0)
+	for _, s := range splitList(values...) {
+		c, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "wrong code '%s'", s)
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.URLs) == 0 {
+		return errors.Errorf("no %s URL", c.Name)
+	}
+	for _, u := range c.URLs {
+		if strings.TrimSpace(u) == "" {
+			return errors.Errorf("empty %s URL", c.Name)
+		}
+	}
+	if c.MaxFails < 1 {
+		return errors.Errorf("wrong max fails %d", c.MaxFails)
+	}
+	if c.ProbeInterval <= 0 {
+		return errors.Errorf("wrong probe interval %v", c.ProbeInterval)
+	}
+	if c.BreakerFailures < 0 {
+		return errors.Errorf("wrong breaker failures %d", c.BreakerFailures)
+	}
+	if c.BreakerFailures > 0 && c.BreakerOpenTimeout <= 0 {
+		return errors.Errorf("wrong breaker open timeout %v", c.BreakerOpenTimeout)
+	}
+	if c.BreakerFailures > 0 && c.BreakerHalfOpenCalls < 1 {
+		return errors.Errorf("wrong breaker half-open calls %d", c.BreakerHalfOpenCalls)
+	}
+	if c.MaxAttempts < 1 {
+		return errors.Errorf("wrong max attempts %d", c.MaxAttempts)
+	}
+	for _, d := range c.Backoff {
+		if d < 0 {
+			return errors.Errorf("wrong backoff %v", d)
+		}
+	}
+	for _, code := range c.RetryCodes {
+		if code < 100 || code > 599 {
+			return errors.Errorf("wrong retry code %d", code)
+		}
+	}
+	if c.LimitMin < 1 || c.LimitMin > c.LimitMax {
+		return errors.Errorf("wrong limit bounds [%d, %d]", c.LimitMin, c.LimitMax)
+	}
+	if c.LimitInitial < c.LimitMin || c.LimitInitial > c.LimitMax {
+		return errors.Errorf("wrong initial limit %d, must be in [%d, %d]", c.LimitInitial, c.LimitMin, c.LimitMax)
+	}
+	if c.LimitDecrease <= 0 || c.LimitDecrease >= 1 {
+		return errors.Errorf("wrong limit decrease %v", c.LimitDecrease)
+	}
+	if c.LimitLatency < 0 {
+		return errors.Errorf("wrong limit latency %v", c.LimitLatency)
+	}
+	if c.QueueSize < 1 {
+		return errors.Errorf("wrong queue size %d", c.QueueSize)
+	}
+	return nil
+}
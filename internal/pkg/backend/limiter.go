@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+)
+
+//Limiter limits the parallel calls to the backend by the AIMD rule. The limit grows by one after
+//a limit count of successful calls made while the limit is used (additive increase). It is multiplied by
+//LimitDecrease after a failed or a slow call (multiplicative decrease). The limit stays in [LimitMin, LimitMax].
+//The waiting calls get the slots by the priority from ctx. If the queue is full the newest call with the lowest
+//priority is dropped
+type Limiter struct {
+	name      string
+	min, max  float64
+	decrease  float64
+	latency   time.Duration
+	queueSize int
+	now       func() time.Time
+
+	lock     sync.Mutex
+	limit    float64
+	inFlight int
+	epoch    int
+	waiting  []*waiter
+}
+
+type waiter struct {
+	c        chan struct{}
+	priority Priority
+	shed     bool
+}
+
+//Permit is a granted call slot, it must be returned with Limiter.Release
+type Permit struct {
+	start time.Time
+	epoch int
+}
+
+//CallResult is the outcome of the call reported to the limiter, the breaker and the balancer
+type CallResult int
+
+const (
+	//CallIgnored - the call is not counted, e.g. it is canceled or rejected by the client
+	CallIgnored CallResult = iota
+	//CallOK - the call succeeded
+	CallOK
+	//CallFailed - the backend failed or is overloaded
+	CallFailed
+)
+
+//NewLimiter creates the limiter from the limit settings of cfg
+func NewLimiter(cfg Config) *Limiter {
+	res := &Limiter{name: cfg.Name, min: float64(cfg.LimitMin), max: float64(cfg.LimitMax), decrease: cfg.LimitDecrease,
+		latency: cfg.LimitLatency, queueSize: cfg.QueueSize, limit: float64(cfg.LimitInitial), now: time.Now}
+	concurrencyLimit.WithLabelValues(cfg.Name).Set(float64(res.current()))
+	return res
+}
+
+//Acquire waits for a free slot. Returns utils.ErrTooBusy if no slot is free after timeout or the call is
+//dropped from the full queue, ctx.Err() if ctx is done
+func (l *Limiter) Acquire(ctx context.Context, timeout time.Duration) (*Permit, error) {
+	l.lock.Lock()
+	if len(l.waiting) == 0 && l.inFlight < l.current() {
+		defer l.lock.Unlock()
+		return l.grant(), nil
+	}
+	w := &waiter{c: make(chan struct{}), priority: PriorityFrom(ctx)}
+	if !l.enqueue(w) {
+		l.lock.Unlock()
+		totalShed.WithLabelValues(l.name, w.priority.String()).Inc()
+		return nil, utils.ErrTooBusy
+	}
+	l.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.c:
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		if w.shed {
+			return nil, utils.ErrTooBusy
+		}
+		return l.permit(), nil
+	case <-timer.C:
+		err = utils.ErrTooBusy
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.remove(w) || w.shed {
+		return nil, err
+	}
+	// the slot was granted at the same time
+	l.inFlight--
+	l.wakeUp()
+	return nil, err
+}
+
+//Release returns the slot and adjusts the limit by the call result
+func (l *Limiter) Release(p *Permit, res CallResult) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	used := l.inFlight >= l.current()
+	l.inFlight--
+	defer l.wakeUp()
+	if res == CallIgnored {
+		return
+	}
+	failed := res == CallFailed
+	slow := l.latency > 0 && l.now().Sub(p.start) > l.latency
+	if failed || slow {
+		// one decrease for the calls started with the same limit
+		if p.epoch == l.epoch {
+			l.limit = maxF(l.min, l.limit*l.decrease)
+			l.epoch++
+			goapp.Log.Infof("Decreasing %s limit to %d, failed: %t, slow: %t", l.name, l.current(), failed, slow)
+		}
+	} else if used {
+		l.limit = minF(l.max, l.limit+1/l.limit)
+	}
+	concurrencyLimit.WithLabelValues(l.name).Set(float64(l.current()))
+}
+
+//Limit returns the current limit
+func (l *Limiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.current()
+}
+
+//InFlight returns the count of running calls
+func (l *Limiter) InFlight() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.inFlight
+}
+
+func (l *Limiter) current() int {
+	return int(l.limit)
+}
+
+func (l *Limiter) grant() *Permit {
+	l.inFlight++
+	return l.permit()
+}
+
+func (l *Limiter) permit() *Permit {
+	return &Permit{start: l.now(), epoch: l.epoch}
+}
+
+func (l *Limiter) wakeUp() {
+	for len(l.waiting) > 0 && l.inFlight < l.current() {
+		w := l.waiting[0]
+		l.waiting = l.waiting[1:]
+		limiterWaiting.WithLabelValues(l.name).Dec()
+		l.inFlight++
+		close(w.c)
+	}
+}
+
+//enqueue adds w after the waiters with the same or higher priority. If the queue is full it drops the last waiter
+//if it has a lower priority than w, returns false if w can't be added
+func (l *Limiter) enqueue(w *waiter) bool {
+	if len(l.waiting) >= l.queueSize {
+		last := l.waiting[len(l.waiting)-1]
+		if last.priority >= w.priority {
+			return false
+		}
+		l.waiting = l.waiting[:len(l.waiting)-1]
+		limiterWaiting.WithLabelValues(l.name).Dec()
+		totalShed.WithLabelValues(l.name, last.priority.String()).Inc()
+		last.shed = true
+		close(last.c)
+	}
+	i := len(l.waiting)
+	for i > 0 && l.waiting[i-1].priority < w.priority {
+		i--
+	}
+	l.waiting = append(l.waiting, nil)
+	copy(l.waiting[i+1:], l.waiting[i:])
+	l.waiting[i] = w
+	limiterWaiting.WithLabelValues(l.name).Inc()
+	return true
+}
+
+func (l *Limiter) remove(w *waiter) bool {
+	for i, c := range l.waiting {
+		if c == w {
+			l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+			limiterWaiting.WithLabelValues(l.name).Dec()
+			return true
+		}
+	}
+	return false
+}
+
+func minF(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxF(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,204 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Acquire(t *testing.T) {
+	l := newTestLimiter(2, 1, 4)
+	p1 := acquire(t, l)
+	acquire(t, l)
+	assert.Equal(t, 2, l.InFlight())
+	_, err := l.Acquire(context.Background(), 10*time.Millisecond)
+	assert.Equal(t, utils.ErrTooBusy, err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		l.Release(p1, CallIgnored)
+	}()
+	p, err := l.Acquire(context.Background(), time.Second)
+	assert.Nil(t, err)
+	assert.NotNil(t, p)
+	assert.Equal(t, 2, l.InFlight())
+	assert.Equal(t, 0, len(l.waiting))
+}
+
+func TestLimiter_Canceled(t *testing.T) {
+	l := newTestLimiter(1, 1, 4)
+	acquire(t, l)
+	ctx, cancelF := context.WithCancel(context.Background())
+	cancelF()
+	_, err := l.Acquire(ctx, time.Second)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, l.InFlight())
+	assert.Equal(t, 0, len(l.waiting))
+}
+
+func TestLimiter_Increase(t *testing.T) {
+	l := newTestLimiter(2, 1, 3)
+	for i := 0; i < 3; i++ {
+		p1, p2 := acquire(t, l), acquire(t, l)
+		l.Release(p1, CallOK)
+		l.Release(p2, CallOK)
+	}
+	assert.Equal(t, 3, l.Limit())
+	for i := 0; i < 10; i++ {
+		p1, p2, p3 := acquire(t, l), acquire(t, l), acquire(t, l)
+		l.Release(p1, CallOK)
+		l.Release(p2, CallOK)
+		l.Release(p3, CallOK)
+	}
+	assert.Equal(t, 3, l.Limit())
+}
+
+func TestLimiter_NoIncreaseIfNotUsed(t *testing.T) {
+	l := newTestLimiter(2, 1, 4)
+	for i := 0; i < 10; i++ {
+		l.Release(acquire(t, l), CallOK)
+	}
+	assert.Equal(t, 2, l.Limit())
+}
+
+func TestLimiter_Decrease(t *testing.T) {
+	l := newTestLimiter(8, 1, 10)
+	ps := []*Permit{acquire(t, l), acquire(t, l), acquire(t, l)}
+	for _, p := range ps {
+		l.Release(p, CallFailed)
+	}
+	assert.Equal(t, 4, l.Limit())
+	l.Release(acquire(t, l), CallFailed)
+	assert.Equal(t, 2, l.Limit())
+	l.Release(acquire(t, l), CallFailed)
+	l.Release(acquire(t, l), CallFailed)
+	assert.Equal(t, 1, l.Limit())
+}
+
+func TestLimiter_DecreaseSlow(t *testing.T) {
+	l := newTestLimiter(4, 1, 10)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	p := acquire(t, l)
+	now = now.Add(11 * time.Second)
+	l.Release(p, CallOK)
+	assert.Equal(t, 2, l.Limit())
+}
+
+func TestLimiter_Ignored(t *testing.T) {
+	l := newTestLimiter(2, 1, 4)
+	p1, p2 := acquire(t, l), acquire(t, l)
+	l.Release(p1, CallIgnored)
+	l.Release(p2, CallIgnored)
+	assert.Equal(t, 2, l.Limit())
+	assert.Equal(t, 0, l.InFlight())
+}
+
+func TestLimiter_WakesUpInOrder(t *testing.T) {
+	l := newTestLimiter(1, 1, 4)
+	p := acquire(t, l)
+	res := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func(i int) {
+			p, err := l.Acquire(context.Background(), time.Second)
+			require.Nil(t, err)
+			res <- i
+			l.Release(p, CallIgnored)
+		}(i)
+		waitFor(t, func() bool { return l.waitingCount() == i+1 })
+	}
+	l.Release(p, CallIgnored)
+	assert.Equal(t, 0, <-res)
+	assert.Equal(t, 1, <-res)
+}
+
+func TestNewBalancer_FailLimits(t *testing.T) {
+	for _, f := range []func(c *Config){
+		func(c *Config) { c.LimitMin = 0 },
+		func(c *Config) { c.LimitMin, c.LimitMax = 5, 4 },
+		func(c *Config) { c.LimitInitial = 100 },
+		func(c *Config) { c.LimitDecrease = 1 },
+		func(c *Config) { c.LimitLatency = -time.Second },
+		func(c *Config) { c.QueueSize = 0 },
+	} {
+		cfg := DefaultConfig("lex", "u1")
+		f(&cfg)
+		_, err := NewBalancer(cfg)
+		assert.NotNil(t, err)
+	}
+}
+
+func newTestLimiter(initial, min, max int) *Limiter {
+	cfg := DefaultConfig("lex", "u1")
+	cfg.LimitInitial, cfg.LimitMin, cfg.LimitMax = initial, min, max
+	return NewLimiter(cfg)
+}
+
+func acquire(t *testing.T, l *Limiter) *Permit {
+	t.Helper()
+	p, err := l.Acquire(context.Background(), time.Second)
+	require.Nil(t, err)
+	return p
+}
+
+func (l *Limiter) waitingCount() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.waiting)
+}
+
+func waitFor(t *testing.T, f func() bool) {
+	t.Helper()
+	for i := 0; i < 100 && !f(); i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.True(t, f())
+}
+
+func TestLimiter_Priority(t *testing.T) {
+	l := newTestLimiter(1, 1, 4)
+	p := acquire(t, l)
+	res := make(chan Priority, 3)
+	for i, pr := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		go func(pr Priority) {
+			p, err := l.Acquire(WithPriority(context.Background(), pr), time.Second)
+			require.Nil(t, err)
+			res <- pr
+			l.Release(p, CallIgnored)
+		}(pr)
+		i := i
+		waitFor(t, func() bool { return l.waitingCount() == i+1 })
+	}
+	l.Release(p, CallIgnored)
+	assert.Equal(t, PriorityHigh, <-res)
+	assert.Equal(t, PriorityNormal, <-res)
+	assert.Equal(t, PriorityLow, <-res)
+}
+
+func TestLimiter_ShedsLow(t *testing.T) {
+	cfg := DefaultConfig("lex", "u1")
+	cfg.LimitInitial, cfg.QueueSize = 1, 1
+	l := NewLimiter(cfg)
+	acquire(t, l)
+	errC := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(WithPriority(context.Background(), PriorityLow), time.Second)
+		errC <- err
+	}()
+	waitFor(t, func() bool { return l.waitingCount() == 1 })
+
+	_, err := l.Acquire(WithPriority(context.Background(), PriorityLow), time.Second)
+	assert.Equal(t, utils.ErrTooBusy, err)
+
+	go func() {
+		_, _ = l.Acquire(WithPriority(context.Background(), PriorityHigh), time.Second)
+	}()
+	assert.Equal(t, utils.ErrTooBusy, <-errC)
+	waitFor(t, func() bool { return l.waitingCount() == 1 })
+	assert.Equal(t, PriorityHigh, l.waiting[0].priority)
+	assert.Equal(t, 1, l.InFlight())
+}
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var totalRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "backend_requests_total",
+	Help:      "The total number of requests to the backend endpoint",
+}, []string{"backend", "endpoint", "result"})
+
+var outstanding = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "tag",
+	Name:      "backend_outstanding_requests",
+	Help:      "The count of running requests to the backend endpoint",
+}, []string{"backend", "endpoint"})
+
+var endpointUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "tag",
+	Name:      "backend_endpoint_up",
+	Help:      "1 if the backend endpoint is used, 0 if it is ejected",
+}, []string{"backend", "endpoint"})
+
+var breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "tag",
+	Name:      "backend_breaker_state",
+	Help:      "The state of the backend circuit breaker: 0 - closed, 1 - open, 2 - half-open",
+}, []string{"backend"})
+
+var totalBreakerRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "backend_breaker_rejected_total",
+	Help:      "The total number of calls rejected by the open backend circuit breaker",
+}, []string{"backend"})
+
+var totalRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "backend_retries_total",
+	Help:      "The total number of retried backend calls",
+}, []string{"backend"})
+
+var totalBudgetRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "backend_retry_budget_rejected_total",
+	Help:      "The total number of retries dropped because the retry budget is exhausted",
+}, []string{"backend"})
+
+var concurrencyLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "tag",
+	Name:      "backend_concurrency_limit",
+	Help:      "The current limit of parallel calls to the backend",
+}, []string{"backend"})
+
+var limiterWaiting = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "tag",
+	Name:      "backend_limiter_waiting",
+	Help:      "The count of calls waiting for a free backend slot",
+}, []string{"backend"})
+
+var totalShed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "backend_shed_total",
+	Help:      "The total number of calls dropped because the backend queue is full",
+}, []string{"backend", "priority"})
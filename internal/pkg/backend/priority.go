@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Priority is the class of the request, higher priority calls wait less for a backend slot
+type Priority int
+
+const (
+	//PriorityLow is for bulk traffic, such calls are shed first
+	PriorityLow Priority = iota - 1
+	//PriorityNormal is the default priority
+	PriorityNormal
+	//PriorityHigh is for interactive traffic
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	}
+	return "normal"
+}
+
+//ParsePriority parses low, normal or high
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return PriorityNormal, errors.Errorf("wrong priority '%s'", s)
+}
+
+type priorityKey struct{}
+
+//WithPriority returns ctx with the priority of the backend calls
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+//PriorityFrom returns the priority set by WithPriority, PriorityNormal if not set
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePriority(t *testing.T) {
+	for s, want := range map[string]Priority{"low": PriorityLow, "Normal": PriorityNormal, " high ": PriorityHigh} {
+		p, err := ParsePriority(s)
+		assert.Nil(t, err)
+		assert.Equal(t, want, p)
+		assert.Equal(t, want, must(ParsePriority(p.String())))
+	}
+	_, err := ParsePriority("urgent")
+	assert.NotNil(t, err)
+}
+
+func TestPriorityFrom(t *testing.T) {
+	assert.Equal(t, PriorityNormal, PriorityFrom(context.Background()))
+	assert.Equal(t, PriorityLow, PriorityFrom(WithPriority(context.Background(), PriorityLow)))
+}
+
+func must(p Priority, err error) Priority {
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+)
+
+//Retrier repeats the failed calls by the backend retry policy
+type Retrier struct {
+	name        string
+	backoff     []time.Duration
+	maxAttempts int
+	codes       map[int]bool
+	retryErrors bool
+	budget      *Budget
+}
+
+//NewRetrier creates the retrier from the retry settings of cfg
+func NewRetrier(cfg Config) *Retrier {
+	res := &Retrier{name: cfg.Name, backoff: cfg.Backoff, maxAttempts: cfg.MaxAttempts, codes: map[int]bool{},
+		retryErrors: cfg.RetryErrors, budget: cfg.Budget}
+	for _, c := range cfg.RetryCodes {
+		res.codes[c] = true
+	}
+	return res
+}
+
+//RetryCode checks if the call must be retried after the response with the code
+func (r *Retrier) RetryCode(code int) bool {
+	return r.codes[code]
+}
+
+//RetryErrors checks if the call must be retried after a connection or response decoding error
+func (r *Retrier) RetryErrors() bool {
+	return r.retryErrors
+}
+
+//Do invokes f until f returns retry=false or the attempts are over or the retry budget is exhausted.
+//Returns the last error of f
+func (r *Retrier) Do(ctx context.Context, f func(ctx context.Context) (bool, error)) error {
+	r.budget.request()
+	var err error
+	for i := 0; i < r.maxAttempts; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if i > 0 {
+			if !r.budget.allowRetry() {
+				totalBudgetRejected.WithLabelValues(r.name).Inc()
+				goapp.Log.Warnf("No retry for %s, the retry budget is exhausted", r.name)
+				return err
+			}
+			totalRetries.WithLabelValues(r.name).Inc()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-utils.RandomWait(int(r.wait(i) / time.Millisecond)):
+			}
+		}
+		var retry bool
+		retry, err = f(ctx)
+		if !retry {
+			return err
+		}
+		if err != nil {
+			goapp.Log.Warn(err)
+		}
+	}
+	return err
+}
+
+//wait returns the backoff before the attempt, the last value is used if the list is shorter
+func (r *Retrier) wait(attempt int) time.Duration {
+	if len(r.backoff) == 0 {
+		return 0
+	}
+	return r.backoff[min(attempt, len(r.backoff))-1]
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
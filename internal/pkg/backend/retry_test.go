@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrier_Codes(t *testing.T) {
+	r := NewRetrier(DefaultConfig("lex", "u1"))
+	assert.True(t, r.RetryCode(429))
+	assert.True(t, r.RetryCode(503))
+	assert.False(t, r.RetryCode(500))
+	assert.True(t, r.RetryErrors())
+
+	cfg := DefaultConfig("lex", "u1")
+	cfg.RetryCodes, cfg.RetryErrors = []int{500}, false
+	r = NewRetrier(cfg)
+	assert.False(t, r.RetryCode(503))
+	assert.True(t, r.RetryCode(500))
+	assert.False(t, r.RetryErrors())
+}
+
+func TestRetrier_Do(t *testing.T) {
+	r := newTestRetrier(5)
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) (bool, error) {
+		calls++
+		return calls < 3, errors.New("err")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetrier_DoOK(t *testing.T) {
+	r := newTestRetrier(5)
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) (bool, error) {
+		calls++
+		return false, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetrier_MaxAttempts(t *testing.T) {
+	r := newTestRetrier(4)
+	calls := 0
+	err := r.Do(context.Background(), func(ctx context.Context) (bool, error) {
+		calls++
+		return true, errors.New("err")
+	})
+	assert.Equal(t, "err", err.Error())
+	assert.Equal(t, 4, calls)
+}
+
+func TestRetrier_Budget(t *testing.T) {
+	r := newTestRetrier(5)
+	var err error
+	r.budget, err = NewBudget(0, 2, time.Minute)
+	require.Nil(t, err)
+	calls := 0
+	f := func(ctx context.Context) (bool, error) {
+		calls++
+		return true, errors.New("err")
+	}
+	assert.NotNil(t, r.Do(context.Background(), f))
+	assert.Equal(t, 3, calls)
+	calls = 0
+	assert.NotNil(t, r.Do(context.Background(), f))
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetrier_Canceled(t *testing.T) {
+	cfg := DefaultConfig("lex", "u1")
+	cfg.Backoff = []time.Duration{time.Minute}
+	r := NewRetrier(cfg)
+	ctx, cancelF := context.WithCancel(context.Background())
+	calls := 0
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancelF()
+	}()
+	err := r.Do(ctx, func(ctx context.Context) (bool, error) {
+		calls++
+		return true, errors.New("err")
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetrier_Wait(t *testing.T) {
+	cfg := DefaultConfig("lex", "u1")
+	cfg.Backoff = []time.Duration{time.Second, 2 * time.Second}
+	r := NewRetrier(cfg)
+	assert.Equal(t, time.Second, r.wait(1))
+	assert.Equal(t, 2*time.Second, r.wait(2))
+	assert.Equal(t, 2*time.Second, r.wait(5))
+	r.backoff = nil
+	assert.Equal(t, time.Duration(0), r.wait(1))
+}
+
+func TestParseDurations(t *testing.T) {
+	v, err := ParseDurations("10ms, 1s", "2m")
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, time.Second, 2 * time.Minute}, v)
+	_, err = ParseDurations("10")
+	assert.NotNil(t, err)
+}
+
+func TestParseCodes(t *testing.T) {
+	v, err := ParseCodes("429, 503", "500")
+	assert.Nil(t, err)
+	assert.Equal(t, []int{429, 503, 500}, v)
+	_, err = ParseCodes("5xx")
+	assert.NotNil(t, err)
+}
+
+func newTestRetrier(maxAttempts int) *Retrier {
+	cfg := DefaultConfig("lex", "u1")
+	cfg.Backoff = []time.Duration{time.Millisecond}
+	cfg.MaxAttempts = maxAttempts
+	return NewRetrier(cfg)
+}
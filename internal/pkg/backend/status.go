@@ -0,0 +1,28 @@
+package backend
+
+//Status is the state of one backend
+type Status struct {
+	Name      string           `json:"name"`
+	Breaker   string           `json:"breaker"`
+	Limit     int              `json:"limit"`
+	InFlight  int              `json:"inFlight"`
+	Endpoints []EndpointStatus `json:"endpoints"`
+}
+
+//EndpointStatus is the state of one backend replica
+type EndpointStatus struct {
+	URL         string `json:"url"`
+	Up          bool   `json:"up"`
+	Outstanding int    `json:"outstanding"`
+}
+
+//NewStatus collects the state of the balancer endpoints, the breaker and the limiter
+func NewStatus(b *Balancer, br *Breaker, l *Limiter) Status {
+	res := Status{Name: b.name, Breaker: br.State().String(), Limit: l.Limit(), InFlight: l.InFlight()}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for _, e := range b.endpoints {
+		res.Endpoints = append(res.Endpoints, EndpointStatus{URL: e.URL, Up: !e.ejected, Outstanding: e.outstanding})
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package batching
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+)
+
+type item struct {
+	text string
+	len  int
+	data interface{}
+	// left is set if the caller does not wait for the result anymore
+	left     bool
+	priority backend.Priority
+
+	result interface{}
+	err    error
+	done   chan struct{}
+}
+
+type batch struct {
+	items []*item
+	len   int
+	// waiting is the count of callers waiting for the result, ctx is canceled when all callers leave
+	waiting int
+	ctx     context.Context
+	cancelF context.CancelFunc
+}
+
+//batcher collects items arriving within the window and passes them to run together
+type batcher struct {
+	window   time.Duration
+	maxItems int
+	maxLen   int
+	run      func(ctx context.Context, items []*item)
+
+	lock    sync.Mutex
+	current *batch
+}
+
+//add puts the item into the current batch and waits for the result or for ctx to be done
+func (b *batcher) add(ctx context.Context, it *item) (interface{}, error) {
+	it.done = make(chan struct{})
+	it.priority = backend.PriorityFrom(ctx)
+	b.lock.Lock()
+	if b.current == nil {
+		b.current = &batch{}
+		b.current.ctx, b.current.cancelF = context.WithCancel(context.Background())
+		cb := b.current
+		time.AfterFunc(b.window, func() { b.flush(cb) })
+	}
+	bt := b.current
+	bt.items = append(bt.items, it)
+	bt.len += it.len
+	bt.waiting++
+	full := len(bt.items) >= b.maxItems || bt.len >= b.maxLen
+	if full {
+		b.current = nil
+	}
+	b.lock.Unlock()
+
+	if full {
+		go b.runBatch(bt)
+	}
+	select {
+	case <-it.done:
+		return it.result, it.err
+	case <-ctx.Done():
+		b.leave(bt, it)
+		return nil, ctx.Err()
+	}
+}
+
+func (b *batcher) leave(bt *batch, it *item) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	it.left = true
+	bt.waiting--
+	if bt.waiting == 0 {
+		if b.current == bt {
+			b.current = nil
+		}
+		bt.cancelF()
+	}
+}
+
+func (b *batcher) flush(bt *batch) {
+	b.lock.Lock()
+	if b.current != bt {
+		b.lock.Unlock()
+		return
+	}
+	b.current = nil
+	b.lock.Unlock()
+	b.runBatch(bt)
+}
+
+//runBatch runs the items which callers are still waiting with the highest priority of the items
+func (b *batcher) runBatch(bt *batch) {
+	defer bt.cancelF()
+	b.lock.Lock()
+	items := make([]*item, 0, len(bt.items))
+	pr := backend.PriorityLow
+	for _, it := range bt.items {
+		if !it.left {
+			items = append(items, it)
+			if it.priority > pr {
+				pr = it.priority
+			}
+		}
+	}
+	b.lock.Unlock()
+	if len(items) > 0 {
+		b.run(backend.WithPriority(bt.ctx, pr), items)
+	}
+}
+
+func finish(items []*item, err error) {
+	for _, it := range items {
+		if err != nil {
+			it.err = err
+		}
+		close(it.done)
+	}
+}
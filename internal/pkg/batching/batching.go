@@ -0,0 +1,169 @@
+package batching
+
+import (
+	"context"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+type (
+	segmenter interface {
+		Process(ctx context.Context, text string) (*api.SegmenterResult, error)
+	}
+
+	tagger interface {
+		Process(context.Context, string, *api.SegmenterResult) (*api.TaggerResult, error)
+	}
+)
+
+//Segmenter merges small texts arriving at the same time into one segmenter call
+type Segmenter struct {
+	real segmenter
+	b    *batcher
+}
+
+//NewSegmenter creates a batching segmenter.
+//Texts are collected for window, a batch is sent earlier if it has maxItems texts or maxLen runes.
+//Longer texts are sent directly
+func NewSegmenter(real segmenter, window time.Duration, maxItems, maxLen int) (*Segmenter, error) {
+	if real == nil {
+		return nil, errors.New("no segmenter")
+	}
+	if err := validate(window, maxItems, maxLen); err != nil {
+		return nil, err
+	}
+	res := &Segmenter{real: real}
+	res.b = &batcher{window: window, maxItems: maxItems, maxLen: maxLen, run: res.run}
+	return res, nil
+}
+
+//Process segments the text. The merged call is canceled only if all callers of the batch leave
+func (s *Segmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	l := len([]rune(text))
+	if l >= s.b.maxLen {
+		return s.real.Process(ctx, text)
+	}
+	res, err := s.b.add(ctx, &item{text: text, len: l})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*api.SegmenterResult), nil
+}
+
+func (s *Segmenter) run(ctx context.Context, items []*item) {
+	if len(items) == 1 {
+		it := items[0]
+		it.result, it.err = s.real.Process(ctx, it.text)
+		finish(items, nil)
+		return
+	}
+	totalBatches.WithLabelValues("segmenter").Inc()
+	totalBatchedTexts.WithLabelValues("segmenter").Add(float64(len(items)))
+	goapp.Log.Debugf("Segment %d texts in one call", len(items))
+	text, offsets := join(items)
+	r, err := s.real.Process(ctx, text)
+	if err != nil {
+		finish(items, err)
+		return
+	}
+	for i, it := range items {
+		an, err := utils.CutAnalysis(&api.Analysis{Segments: r}, offsets[i], offsets[i]+it.len)
+		if err != nil {
+			finish(items, err)
+			return
+		}
+		it.result = an.Segments
+	}
+	finish(items, nil)
+}
+
+//Tagger merges small texts arriving at the same time into one tagger call
+type Tagger struct {
+	real tagger
+	b    *batcher
+}
+
+//NewTagger creates a batching tagger.
+//Texts are collected for window, a batch is sent earlier if it has maxItems texts or maxLen runes.
+//Longer texts are sent directly
+func NewTagger(real tagger, window time.Duration, maxItems, maxLen int) (*Tagger, error) {
+	if real == nil {
+		return nil, errors.New("no tagger")
+	}
+	if err := validate(window, maxItems, maxLen); err != nil {
+		return nil, err
+	}
+	res := &Tagger{real: real}
+	res.b = &batcher{window: window, maxItems: maxItems, maxLen: maxLen, run: res.run}
+	return res, nil
+}
+
+//Process tags the text. The merged call is canceled only if all callers of the batch leave
+func (t *Tagger) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	l := len([]rune(text))
+	if l >= t.b.maxLen || data == nil || len(data.Seg) == 0 || len(data.S) == 0 {
+		return t.real.Process(ctx, text, data)
+	}
+	res, err := t.b.add(ctx, &item{text: text, len: l, data: data})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*api.TaggerResult), nil
+}
+
+func (t *Tagger) run(ctx context.Context, items []*item) {
+	if len(items) == 1 {
+		it := items[0]
+		it.result, it.err = t.real.Process(ctx, it.text, it.data.(*api.SegmenterResult))
+		finish(items, nil)
+		return
+	}
+	totalBatches.WithLabelValues("tagger").Inc()
+	totalBatchedTexts.WithLabelValues("tagger").Add(float64(len(items)))
+	goapp.Log.Debugf("Tag %d texts in one call", len(items))
+	text, offsets := join(items)
+	sd := make([]*api.SegmenterResult, len(items))
+	for i, it := range items {
+		sd[i] = it.data.(*api.SegmenterResult)
+	}
+	data := utils.JoinSegments(sd, offsets)
+	r, err := t.real.Process(ctx, text, data)
+	if err != nil {
+		finish(items, err)
+		return
+	}
+	for i, it := range items {
+		an, err := utils.CutAnalysis(&api.Analysis{Segments: data, Tags: r}, offsets[i], offsets[i]+it.len)
+		if err != nil {
+			finish(items, err)
+			return
+		}
+		it.result = an.Tags
+	}
+	finish(items, nil)
+}
+
+func join(items []*item) (string, []int) {
+	texts := make([]string, len(items))
+	for i, it := range items {
+		texts[i] = it.text
+	}
+	return utils.JoinTexts(texts)
+}
+
+func validate(window time.Duration, maxItems, maxLen int) error {
+	if window <= 0 {
+		return errors.Errorf("wrong window %v", window)
+	}
+	if maxItems < 1 {
+		return errors.Errorf("wrong max items %d", maxItems)
+	}
+	if maxLen < 1 {
+		return errors.Errorf("wrong max len %d", maxLen)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package batching
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Fail(t *testing.T) {
+	_, err := NewSegmenter(nil, time.Millisecond, 10, 100)
+	assert.NotNil(t, err)
+	_, err = NewSegmenter(&testSegmenter{}, 0, 10, 100)
+	assert.NotNil(t, err)
+	_, err = NewSegmenter(&testSegmenter{}, time.Millisecond, 0, 100)
+	assert.NotNil(t, err)
+	_, err = NewSegmenter(&testSegmenter{}, time.Millisecond, 10, 0)
+	assert.NotNil(t, err)
+	_, err = NewTagger(nil, time.Millisecond, 10, 100)
+	assert.NotNil(t, err)
+	_, err = NewTagger(&testTagger{}, 0, 10, 100)
+	assert.NotNil(t, err)
+}
+
+func TestSegmenter_One(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, time.Millisecond, 10, 100)
+	r, err := s.Process(context.Background(), "aa bb")
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 2}, {3, 2}}, r.Seg)
+	assert.Equal(t, []string{"aa bb"}, ts.texts)
+}
+
+func TestSegmenter_Long(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, time.Hour, 10, 5)
+	r, err := s.Process(context.Background(), "aa bb")
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 2}, {3, 2}}, r.Seg)
+}
+
+func TestSegmenter_Merges(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, time.Hour, 3, 100)
+	texts := []string{"aa bb", "cc", "dd ee ff"}
+	res := make([]*api.SegmenterResult, len(texts))
+	wg := sync.WaitGroup{}
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			res[i], err = s.Process(context.Background(), texts[i])
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, 1, len(ts.texts))
+	assert.Equal(t, len("aa bb")+len("cc")+len("dd ee ff")+2*len(utils.TextSeparator), len(ts.texts[0]))
+	assert.Equal(t, &api.SegmenterResult{Seg: [][]int{{0, 2}, {3, 2}}, S: [][]int{{0, 5}}, P: [][]int{{0, 5}}}, res[0])
+	assert.Equal(t, &api.SegmenterResult{Seg: [][]int{{0, 2}}, S: [][]int{{0, 2}}, P: [][]int{{0, 2}}}, res[1])
+	assert.Equal(t, &api.SegmenterResult{Seg: [][]int{{0, 2}, {3, 2}, {6, 2}}, S: [][]int{{0, 8}},
+		P: [][]int{{0, 8}}}, res[2])
+}
+
+func TestSegmenter_Priority(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, time.Hour, 3, 100)
+	wg := sync.WaitGroup{}
+	for _, pr := range []backend.Priority{backend.PriorityLow, backend.PriorityHigh, backend.PriorityNormal} {
+		wg.Add(1)
+		go func(pr backend.Priority) {
+			defer wg.Done()
+			_, err := s.Process(backend.WithPriority(context.Background(), pr), "aa")
+			assert.Nil(t, err)
+		}(pr)
+	}
+	wg.Wait()
+	assert.Equal(t, []backend.Priority{backend.PriorityHigh}, ts.priorities)
+}
+
+func TestSegmenter_Window(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, 50*time.Millisecond, 10, 100)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Process(context.Background(), "aa bb")
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, len(ts.texts))
+}
+
+func TestSegmenter_Fail(t *testing.T) {
+	ts := &testSegmenter{err: errors.New("olia")}
+	s, _ := NewSegmenter(ts, time.Hour, 2, 100)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Process(context.Background(), "aa bb")
+			assert.NotNil(t, err)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSegmenter_Leave(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, 50*time.Millisecond, 10, 100)
+	ctx, cancelF := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancelF()
+	}()
+	_, err := s.Process(ctx, "aa bb")
+	assert.Equal(t, context.Canceled, err)
+	time.Sleep(60 * time.Millisecond)
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	assert.Equal(t, 0, len(ts.texts))
+}
+
+func TestSegmenter_LeaveOne(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, 50*time.Millisecond, 10, 100)
+	ctx, cancelF := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, err := s.Process(ctx, "aa")
+		assert.Equal(t, context.Canceled, err)
+	}()
+	go func() {
+		defer wg.Done()
+		r, err := s.Process(context.Background(), "bb cc")
+		assert.Nil(t, err)
+		require.NotNil(t, r)
+		assert.Equal(t, [][]int{{0, 2}, {3, 2}}, r.Seg)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancelF()
+	wg.Wait()
+	assert.Equal(t, []string{"bb cc"}, ts.texts)
+}
+
+func TestTagger_Merges(t *testing.T) {
+	tt := &testTagger{}
+	tg, _ := NewTagger(tt, time.Hour, 2, 100)
+	texts := []string{"aa bb", "cc"}
+	res := make([]*api.TaggerResult, len(texts))
+	wg := sync.WaitGroup{}
+	for i := range texts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			res[i], err = tg.Process(context.Background(), texts[i], segment(texts[i]))
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, 1, len(tt.texts))
+	assert.Equal(t, [][][]string{{{"aa", "X-"}}, {{"bb", "X-"}}}, res[0].Msd)
+	assert.Equal(t, [][][]string{{{"cc", "X-"}}}, res[1].Msd)
+}
+
+func TestTagger_WrongResult(t *testing.T) {
+	tt := &testTagger{skipLast: true}
+	tg, _ := NewTagger(tt, time.Hour, 2, 100)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tg.Process(context.Background(), "aa bb", segment("aa bb"))
+			assert.NotNil(t, err)
+		}()
+	}
+	wg.Wait()
+}
+
+type testSegmenter struct {
+	lock       sync.Mutex
+	texts      []string
+	priorities []backend.Priority
+	err        error
+}
+
+func (s *testSegmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	s.lock.Lock()
+	s.texts = append(s.texts, text)
+	s.priorities = append(s.priorities, backend.PriorityFrom(ctx))
+	s.lock.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return segment(text), nil
+}
+
+type testTagger struct {
+	lock     sync.Mutex
+	texts    []string
+	skipLast bool
+}
+
+func (s *testTagger) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	s.lock.Lock()
+	s.texts = append(s.texts, text)
+	s.lock.Unlock()
+	rns := []rune(text)
+	res := &api.TaggerResult{}
+	for _, s := range data.Seg {
+		res.Msd = append(res.Msd, [][]string{{string(rns[s[0] : s[0]+s[1]]), "X-"}})
+	}
+	if s.skipLast {
+		res.Msd = res.Msd[:len(res.Msd)-1]
+	}
+	return res, nil
+}
+
+//segment makes a word per single space separated token, a sentence and a paragraph per non empty line
+func segment(text string) *api.SegmenterResult {
+	res := &api.SegmenterResult{}
+	pos := 0
+	for _, l := range strings.SplitAfter(text, "\n") {
+		ll := len([]rune(l))
+		if strings.TrimSpace(l) != "" {
+			tl := len([]rune(strings.TrimRight(l, "\n")))
+			res.S = append(res.S, []int{pos, tl})
+			res.P = append(res.P, []int{pos, tl})
+			wp := pos
+			for _, w := range strings.Fields(l) {
+				wl := len([]rune(w))
+				res.Seg = append(res.Seg, []int{wp, wl})
+				wp += wl + 1
+			}
+		}
+		pos += ll
+	}
+	return res
+}
@@ -0,0 +1,18 @@
+package batching
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var totalBatches = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "batches_total",
+	Help:      "The total number of merged backend calls",
+}, []string{"backend"})
+
+var totalBatchedTexts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "batched_texts_total",
+	Help:      "The total number of texts sent in merged backend calls",
+}, []string{"backend"})
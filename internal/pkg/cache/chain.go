@@ -0,0 +1,39 @@
+package cache
+
+import "github.com/airenas/lt-pos-tagger/internal/pkg/api"
+
+//Store is a cache used in the chain
+type Store interface {
+	Get(key string) (*api.Analysis, bool)
+	Add(key string, value *api.Analysis)
+}
+
+//Chain looks for the item in several caches, e.g. in memory and on disk
+type Chain struct {
+	caches []Store
+}
+
+//NewChain creates a chain of caches, the first one is checked first
+func NewChain(caches ...Store) *Chain {
+	return &Chain{caches: caches}
+}
+
+//Get returns the value from the first cache having it, the value is added to the previous caches
+func (c *Chain) Get(key string) (*api.Analysis, bool) {
+	for i, s := range c.caches {
+		if res, ok := s.Get(key); ok {
+			for j := 0; j < i; j++ {
+				c.caches[j].Add(key, res)
+			}
+			return res, true
+		}
+	}
+	return nil, false
+}
+
+//Add puts the value into all caches
+func (c *Chain) Add(key string, value *api.Analysis) {
+	for _, s := range c.caches {
+		s.Add(key, value)
+	}
+}
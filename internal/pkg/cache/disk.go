@@ -0,0 +1,382 @@
+package cache
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketMeta    = []byte("meta")
+	bucketItems   = []byte("items")
+	bucketCreated = []byte("created")
+	keyVersion    = []byte("version")
+)
+
+// lockTimeout is the wait for the file lock held by another process
+var lockTimeout = 5 * time.Second
+
+// writes are collected into one transaction
+const (
+	writeQueueSize = 1000
+	maxWriteBatch  = 100
+)
+
+type diskItem struct {
+	Created time.Time     `json:"created"`
+	Value   *api.Analysis `json:"value"`
+}
+
+type diskWrite struct {
+	key     string
+	created time.Time
+	data    []byte
+}
+
+//Disk is a persistent cache in a local file.
+//Items are written in the background in batches, an item is dropped if the write queue is full.
+//Items expire after ttl, the oldest items are dropped if there are more than maxItems.
+//All items are dropped if the version of the file differs
+type Disk struct {
+	db       *bolt.DB
+	ttl      time.Duration
+	maxItems int
+
+	// lock serializes the updates, count is changed only after the update is committed
+	lock  sync.Mutex
+	count int
+
+	writeC chan *diskWrite
+	flushC chan chan struct{}
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+//NewDisk opens or creates the cache file. The file is compacted on start
+func NewDisk(file, version string, ttl time.Duration, maxItems int) (*Disk, error) {
+	if err := validateDisk(file, ttl, maxItems); err != nil {
+		return nil, err
+	}
+	if err := compact(file); err != nil {
+		return nil, errors.Wrapf(err, "can't compact %s", file)
+	}
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't open %s", file)
+	}
+	res := &Disk{db: db, ttl: ttl, maxItems: maxItems, writeC: make(chan *diskWrite, writeQueueSize),
+		flushC: make(chan chan struct{}), closeC: make(chan struct{})}
+	if err := res.init(version); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	res.wg.Add(2)
+	go res.cleaner(cleanInterval(ttl))
+	go res.writer()
+	return res, nil
+}
+
+func validateDisk(file string, ttl time.Duration, maxItems int) error {
+	if file == "" {
+		return errors.New("no cache file")
+	}
+	if ttl <= 0 {
+		return errors.Errorf("wrong ttl %v", ttl)
+	}
+	if maxItems < 1 {
+		return errors.Errorf("wrong max items %d", maxItems)
+	}
+	return nil
+}
+
+func (d *Disk) init(version string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		if string(meta.Get(keyVersion)) != version {
+			goapp.Log.Infof("Cache version changed to '%s', dropping items", version)
+			for _, b := range [][]byte{bucketItems, bucketCreated} {
+				if err := tx.DeleteBucket(b); err != nil && err != bolt.ErrBucketNotFound {
+					return err
+				}
+			}
+			if err := meta.Put(keyVersion, []byte(version)); err != nil {
+				return err
+			}
+		}
+		items, err := tx.CreateBucketIfNotExists(bucketItems)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(bucketCreated); err != nil {
+			return err
+		}
+		d.count = items.Stats().KeyN
+		return nil
+	})
+}
+
+//Get returns the cached value
+func (d *Disk) Get(key string) (*api.Analysis, bool) {
+	var data []byte
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketItems).Get([]byte(key)); v != nil {
+			data = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		if err != nil {
+			goapp.Log.Error(errors.Wrap(err, "can't read cache"))
+		}
+		totalMisses.WithLabelValues(typeDisk).Inc()
+		return nil, false
+	}
+	var it diskItem
+	if err := json.Unmarshal(data, &it); err != nil {
+		goapp.Log.Error(errors.Wrap(err, "can't decode cache item"))
+		totalMisses.WithLabelValues(typeDisk).Inc()
+		return nil, false
+	}
+	if time.Since(it.Created) > d.ttl {
+		totalMisses.WithLabelValues(typeDisk).Inc()
+		return nil, false
+	}
+	totalHits.WithLabelValues(typeDisk).Inc()
+	return it.Value, true
+}
+
+//Add puts the value into the write queue
+func (d *Disk) Add(key string, value *api.Analysis) {
+	it := diskItem{Created: time.Now(), Value: value}
+	data, err := json.Marshal(it)
+	if err != nil {
+		goapp.Log.Error(errors.Wrap(err, "can't encode cache item"))
+		return
+	}
+	select {
+	case d.writeC <- &diskWrite{key: key, created: it.Created, data: data}:
+	default:
+		totalDroppedWrites.Inc()
+	}
+}
+
+//Len returns the count of the written items
+func (d *Disk) Len() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.count
+}
+
+func (d *Disk) writer() {
+	defer d.wg.Done()
+	for {
+		select {
+		case w := <-d.writeC:
+			d.write(d.collect(w, maxWriteBatch))
+		case f := <-d.flushC:
+			d.write(d.collect(nil, writeQueueSize))
+			close(f)
+		case <-d.closeC:
+			d.write(d.collect(nil, writeQueueSize))
+			return
+		}
+	}
+}
+
+//collect takes the waiting writes without blocking
+func (d *Disk) collect(w *diskWrite, max int) []*diskWrite {
+	var res []*diskWrite
+	if w != nil {
+		res = append(res, w)
+	}
+	for len(res) < max {
+		select {
+		case w := <-d.writeC:
+			res = append(res, w)
+		default:
+			return res
+		}
+	}
+	return res
+}
+
+//flush waits until the queued items are written
+func (d *Disk) flush() {
+	f := make(chan struct{})
+	d.flushC <- f
+	<-f
+}
+
+func (d *Disk) write(ws []*diskWrite) {
+	if len(ws) == 0 {
+		return
+	}
+	err := d.update(func(tx *bolt.Tx, count *int) error {
+		items, created := tx.Bucket(bucketItems), tx.Bucket(bucketCreated)
+		for _, w := range ws {
+			if old := items.Get([]byte(w.key)); old != nil {
+				if err := remove(items, created, w.key, old, count); err != nil {
+					return err
+				}
+			}
+			if err := items.Put([]byte(w.key), w.data); err != nil {
+				return err
+			}
+			if err := created.Put(createdKey(w.created, w.key), nil); err != nil {
+				return err
+			}
+			*count++
+		}
+		for *count > d.maxItems {
+			if ok, err := removeOldest(items, created, time.Time{}, count); err != nil || !ok {
+				return err
+			}
+			totalEvictions.WithLabelValues(typeDisk).Inc()
+		}
+		return nil
+	})
+	if err != nil {
+		goapp.Log.Error(errors.Wrapf(err, "can't write %d items to cache", len(ws)))
+	}
+}
+
+//update runs f in a write transaction, the count changed by f is kept only if the transaction is committed
+func (d *Disk) update(f func(tx *bolt.Tx, count *int) error) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	count := d.count
+	if err := d.db.Update(func(tx *bolt.Tx) error {
+		return f(tx, &count)
+	}); err != nil {
+		return err
+	}
+	d.count = count
+	return nil
+}
+
+//Close writes the queued items, stops the cleaner and closes the file
+func (d *Disk) Close() error {
+	close(d.closeC)
+	d.wg.Wait()
+	return d.db.Close()
+}
+
+func (d *Disk) cleaner(interval time.Duration) {
+	defer d.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.closeC:
+			return
+		case <-ticker.C:
+			if err := d.removeExpired(); err != nil {
+				goapp.Log.Error(errors.Wrap(err, "can't clean cache"))
+			}
+		}
+	}
+}
+
+func (d *Disk) removeExpired() error {
+	before := time.Now().Add(-d.ttl)
+	return d.update(func(tx *bolt.Tx, count *int) error {
+		items, created := tx.Bucket(bucketItems), tx.Bucket(bucketCreated)
+		for {
+			ok, err := removeOldest(items, created, before, count)
+			if err != nil || !ok {
+				return err
+			}
+		}
+	})
+}
+
+//removeOldest drops the oldest item if it is created before the time, any time is accepted if before is zero
+func removeOldest(items, created *bolt.Bucket, before time.Time, count *int) (bool, error) {
+	k, _ := created.Cursor().First()
+	if k == nil {
+		return false, nil
+	}
+	t, key := parseCreatedKey(k)
+	if !before.IsZero() && !t.Before(before) {
+		return false, nil
+	}
+	if err := created.Delete(k); err != nil {
+		return false, err
+	}
+	if items.Get([]byte(key)) != nil {
+		*count--
+	}
+	return true, items.Delete([]byte(key))
+}
+
+func remove(items, created *bolt.Bucket, key string, data []byte, count *int) error {
+	var it diskItem
+	if err := json.Unmarshal(data, &it); err == nil {
+		if err := created.Delete(createdKey(it.Created, key)); err != nil {
+			return err
+		}
+	}
+	*count--
+	return items.Delete([]byte(key))
+}
+
+//createdKey is sorted by time: 8 bytes of unix nanos + key
+func createdKey(t time.Time, key string) []byte {
+	res := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(res, uint64(t.UnixNano()))
+	return append(res, key...)
+}
+
+func parseCreatedKey(k []byte) (time.Time, string) {
+	if len(k) < 8 {
+		return time.Time{}, ""
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k[:8]))), string(k[8:])
+}
+
+func cleanInterval(ttl time.Duration) time.Duration {
+	if ttl > 10*time.Minute {
+		return 10 * time.Minute
+	}
+	return ttl
+}
+
+//compact copies the existing file to a new one to free the unused space
+func compact(file string) error {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil
+	}
+	src, err := bolt.Open(file, 0600, &bolt.Options{Timeout: lockTimeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := file + ".compact"
+	_ = os.Remove(tmp)
+	dst, err := bolt.Open(tmp, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return err
+	}
+	if err := bolt.Compact(dst, src, 1<<20); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := src.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
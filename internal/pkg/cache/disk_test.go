@@ -0,0 +1,168 @@
+package cache
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestNewDisk(t *testing.T) {
+	d, err := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 10)
+	require.Nil(t, err)
+	assert.Nil(t, d.Close())
+}
+
+func TestNewDisk_Fail(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "c.db")
+	_, err := NewDisk("", "1", time.Minute, 10)
+	assert.NotNil(t, err)
+	_, err = NewDisk(f, "1", 0, 10)
+	assert.NotNil(t, err)
+	_, err = NewDisk(f, "1", time.Minute, 0)
+	assert.NotNil(t, err)
+}
+
+func TestDisk_Get(t *testing.T) {
+	d, _ := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 10)
+	defer d.Close()
+	_, ok := d.Get("k")
+	assert.False(t, ok)
+	v := testAnalysis()
+	d.Add("k", v)
+	d.flush()
+	r, ok := d.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, v, r)
+}
+
+func TestDisk_Persists(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "c.db")
+	d, _ := NewDisk(f, "1", time.Minute, 10)
+	d.Add("k", testAnalysis())
+	require.Nil(t, d.Close())
+
+	d, err := NewDisk(f, "1", time.Minute, 10)
+	require.Nil(t, err)
+	r, ok := d.Get("k")
+	assert.True(t, ok)
+	assert.Equal(t, testAnalysis(), r)
+	assert.Equal(t, 1, d.Len())
+	require.Nil(t, d.Close())
+}
+
+func TestDisk_Version(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "c.db")
+	d, _ := NewDisk(f, "1", time.Minute, 10)
+	d.Add("k", testAnalysis())
+	require.Nil(t, d.Close())
+
+	d, err := NewDisk(f, "2", time.Minute, 10)
+	require.Nil(t, err)
+	defer d.Close()
+	_, ok := d.Get("k")
+	assert.False(t, ok)
+	assert.Equal(t, 0, d.Len())
+}
+
+func TestDisk_Expires(t *testing.T) {
+	d, _ := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", 50*time.Millisecond, 10)
+	defer d.Close()
+	d.Add("k", testAnalysis())
+	d.flush()
+	time.Sleep(60 * time.Millisecond)
+	_, ok := d.Get("k")
+	assert.False(t, ok)
+	assert.Nil(t, d.removeExpired())
+	assert.Equal(t, 0, d.Len())
+}
+
+func TestDisk_Evicts(t *testing.T) {
+	d, _ := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 2)
+	defer d.Close()
+	d.Add("k1", testAnalysis())
+	d.Add("k2", testAnalysis())
+	d.Add("k1", testAnalysis())
+	d.flush()
+	assert.Equal(t, 2, d.Len())
+	d.Add("k3", testAnalysis())
+	d.flush()
+	assert.Equal(t, 2, d.Len())
+	_, ok := d.Get("k2")
+	assert.False(t, ok)
+	_, ok = d.Get("k1")
+	assert.True(t, ok)
+	_, ok = d.Get("k3")
+	assert.True(t, ok)
+}
+
+func TestDisk_WritesBatch(t *testing.T) {
+	d, _ := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 200)
+	defer d.Close()
+	for i := 0; i < 150; i++ {
+		d.Add(strconv.Itoa(i), testAnalysis())
+	}
+	d.flush()
+	assert.Equal(t, 150, d.Len())
+	_, ok := d.Get("149")
+	assert.True(t, ok)
+}
+
+func TestDisk_DropsWhenQueueFull(t *testing.T) {
+	d, _ := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 2000)
+	defer d.Close()
+	dropped := testutil.ToFloat64(totalDroppedWrites)
+	// hold the writer
+	d.lock.Lock()
+	d.Add("k0", testAnalysis())
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < writeQueueSize+10; i++ {
+		d.Add(strconv.Itoa(i), testAnalysis())
+	}
+	d.lock.Unlock()
+	d.flush()
+	assert.Less(t, d.Len(), writeQueueSize+11)
+	assert.Equal(t, float64(writeQueueSize+11-d.Len()), testutil.ToFloat64(totalDroppedWrites)-dropped)
+}
+
+func TestDisk_CountNotChangedOnRollback(t *testing.T) {
+	d, _ := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 10)
+	defer d.Close()
+	err := d.update(func(tx *bolt.Tx, count *int) error {
+		*count += 5
+		return errors.New("olia")
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, d.Len())
+}
+
+func TestChain(t *testing.T) {
+	m, _ := NewLRU(10, 1000000)
+	d, _ := NewDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 10)
+	defer d.Close()
+	d.Add("k", testAnalysis())
+	d.flush()
+	c := NewChain(m, d)
+	r, ok := c.Get("k")
+	assert.True(t, ok)
+	assert.Equal(t, testAnalysis(), r)
+	assert.Equal(t, 1, m.Len())
+	c.Add("k2", testAnalysis())
+	d.flush()
+	_, ok = d.Get("k2")
+	assert.True(t, ok)
+	_, ok = c.Get("k3")
+	assert.False(t, ok)
+}
+
+func testAnalysis() *api.Analysis {
+	return &api.Analysis{Segments: &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}, P: [][]int{{0, 4}}},
+		Tags: &api.TaggerResult{Msd: [][][]string{{{"mama", "Ncfsnn-"}}}}}
+}
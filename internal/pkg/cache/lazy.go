@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+//LazyDisk opens the disk cache in the background. On a graceful restart the old process keeps the file locked
+//until the new one starts serving, so the open is retried until the lock is released. It misses until the file is open
+type LazyDisk struct {
+	lock sync.RWMutex
+	disk *Disk
+
+	closeC chan struct{}
+	wg     sync.WaitGroup
+}
+
+//NewLazyDisk starts opening the cache file, the open is retried every retry while the file is locked
+func NewLazyDisk(file, version string, ttl time.Duration, maxItems int, retry time.Duration) (*LazyDisk, error) {
+	if err := validateDisk(file, ttl, maxItems); err != nil {
+		return nil, err
+	}
+	if retry <= 0 {
+		return nil, errors.Errorf("wrong retry %v", retry)
+	}
+	res := &LazyDisk{closeC: make(chan struct{})}
+	res.wg.Add(1)
+	go res.open(file, version, ttl, maxItems, retry)
+	return res, nil
+}
+
+func (l *LazyDisk) open(file, version string, ttl time.Duration, maxItems int, retry time.Duration) {
+	defer l.wg.Done()
+	for {
+		d, err := NewDisk(file, version, ttl, maxItems)
+		if err == nil {
+			l.lock.Lock()
+			l.disk = d
+			l.lock.Unlock()
+			goapp.Log.Infof("Opened cache %s", file)
+			return
+		}
+		if !errors.Is(err, bolt.ErrTimeout) {
+			goapp.Log.Error(errors.Wrap(err, "can't open disk cache, it is disabled"))
+			return
+		}
+		goapp.Log.Infof("Cache %s is locked, waiting", file)
+		select {
+		case <-l.closeC:
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (l *LazyDisk) get() *Disk {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.disk
+}
+
+//Get returns the cached value, misses if the file is not open yet
+func (l *LazyDisk) Get(key string) (*api.Analysis, bool) {
+	d := l.get()
+	if d == nil {
+		totalMisses.WithLabelValues(typeDisk).Inc()
+		return nil, false
+	}
+	return d.Get(key)
+}
+
+//Add puts the value into the cache, the value is dropped if the file is not open yet
+func (l *LazyDisk) Add(key string, value *api.Analysis) {
+	if d := l.get(); d != nil {
+		d.Add(key, value)
+	}
+}
+
+//Close stops opening and closes the file
+func (l *LazyDisk) Close() error {
+	close(l.closeC)
+	l.wg.Wait()
+	if d := l.get(); d != nil {
+		return d.Close()
+	}
+	return nil
+}
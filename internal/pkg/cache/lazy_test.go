@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLazyDisk_Fail(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "c.db")
+	_, err := NewLazyDisk("", "1", time.Minute, 10, time.Second)
+	assert.NotNil(t, err)
+	_, err = NewLazyDisk(f, "1", time.Minute, 10, 0)
+	assert.NotNil(t, err)
+}
+
+func TestLazyDisk_WaitsForLock(t *testing.T) {
+	lt := lockTimeout
+	lockTimeout = 20 * time.Millisecond
+	defer func() { lockTimeout = lt }()
+	f := filepath.Join(t.TempDir(), "c.db")
+	d, err := NewDisk(f, "1", time.Minute, 10)
+	require.Nil(t, err)
+	d.Add("k", testAnalysis())
+
+	l, err := NewLazyDisk(f, "1", time.Minute, 10, 10*time.Millisecond)
+	require.Nil(t, err)
+	defer l.Close()
+	time.Sleep(50 * time.Millisecond)
+	_, ok := l.Get("k")
+	assert.False(t, ok)
+	l.Add("k2", testAnalysis())
+
+	require.Nil(t, d.Close())
+	assert.Eventually(t, func() bool { return l.get() != nil }, time.Second, 10*time.Millisecond)
+	r, ok := l.Get("k")
+	assert.True(t, ok)
+	assert.Equal(t, testAnalysis(), r)
+	_, ok = l.Get("k2")
+	assert.False(t, ok)
+}
+
+func TestLazyDisk_Close(t *testing.T) {
+	l, err := NewLazyDisk(filepath.Join(t.TempDir(), "c.db"), "1", time.Minute, 10, time.Second)
+	require.Nil(t, err)
+	assert.Eventually(t, func() bool { return l.get() != nil }, time.Second, 10*time.Millisecond)
+	assert.Nil(t, l.Close())
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/pkg/errors"
+)
+
+type entry struct {
+	key   string
+	value *api.Analysis
+	bytes int
+}
+
+//LRU is a size bounded in-memory cache, the least recently used item is dropped when the cache is full
+type LRU struct {
+	size     int
+	maxBytes int
+
+	lock  sync.Mutex
+	items map[string]*list.Element
+	order *list.List
+	bytes int
+}
+
+//NewLRU creates a cache keeping at most size items taking approximately maxBytes of memory.
+//Items larger than maxBytes are not cached
+func NewLRU(size, maxBytes int) (*LRU, error) {
+	if size < 1 {
+		return nil, errors.Errorf("wrong cache size %d", size)
+	}
+	if maxBytes < 1 {
+		return nil, errors.Errorf("wrong cache max bytes %d", maxBytes)
+	}
+	return &LRU{size: size, maxBytes: maxBytes, items: make(map[string]*list.Element), order: list.New()}, nil
+}
+
+//Get returns the cached value
+func (c *LRU) Get(key string) (*api.Analysis, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		totalMisses.WithLabelValues(typeMemory).Inc()
+		return nil, false
+	}
+	totalHits.WithLabelValues(typeMemory).Inc()
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+//Add puts the value into the cache
+func (c *LRU) Add(key string, value *api.Analysis) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	b := approxSize(key, value)
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+	if b > c.maxBytes {
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value, bytes: b})
+	c.bytes += b
+	for c.order.Len() > c.size || c.bytes > c.maxBytes {
+		c.remove(c.order.Back())
+		totalEvictions.WithLabelValues(typeMemory).Inc()
+	}
+}
+
+func (c *LRU) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	c.order.Remove(el)
+	delete(c.items, e.key)
+	c.bytes -= e.bytes
+}
+
+//Bytes returns the approximate memory size of the cached items
+func (c *LRU) Bytes() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.bytes
+}
+
+// sizes of a slice header and of an int on 64 bit platforms, a string header is counted as a slice
+const (
+	sliceSize = 24
+	intSize   = 8
+)
+
+//approxSize estimates the memory taken by the cached item
+func approxSize(key string, value *api.Analysis) int {
+	res := sliceSize + len(key)
+	if value == nil {
+		return res
+	}
+	if s := value.Segments; s != nil {
+		for _, spans := range [][][]int{s.Seg, s.S, s.P} {
+			res += sliceSize
+			for _, sp := range spans {
+				res += sliceSize + len(sp)*intSize
+			}
+		}
+	}
+	if t := value.Tags; t != nil {
+		res += sliceSize
+		for _, w := range t.Msd {
+			res += sliceSize
+			for _, m := range w {
+				res += sliceSize
+				for _, str := range m {
+					res += sliceSize + len(str)
+				}
+			}
+		}
+		res += sliceSize
+		for _, str := range t.Stem {
+			res += sliceSize + len(str)
+		}
+	}
+	return res
+}
+
+//Len returns the count of cached items
+func (c *LRU) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
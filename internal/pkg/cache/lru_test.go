@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLRU(t *testing.T) {
+	c, err := NewLRU(10, 1000000)
+	assert.Nil(t, err)
+	assert.NotNil(t, c)
+	_, err = NewLRU(0, 1000000)
+	assert.NotNil(t, err)
+	_, err = NewLRU(10, 0)
+	assert.NotNil(t, err)
+}
+
+func TestLRU_Get(t *testing.T) {
+	c, _ := NewLRU(10, 1000000)
+	_, ok := c.Get("k")
+	assert.False(t, ok)
+	v := &api.Analysis{Segments: &api.SegmenterResult{Seg: [][]int{{0, 1}}}}
+	c.Add("k", v)
+	r, ok := c.Get("k")
+	require.True(t, ok)
+	assert.Equal(t, v, r)
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	ev := testutil.ToFloat64(totalEvictions.WithLabelValues(typeMemory))
+	c, _ := NewLRU(2, 1000000)
+	c.Add("k1", &api.Analysis{})
+	c.Add("k2", &api.Analysis{})
+	c.Get("k1")
+	c.Add("k3", &api.Analysis{})
+	assert.Equal(t, 2, c.Len())
+	_, ok := c.Get("k2")
+	assert.False(t, ok)
+	_, ok = c.Get("k1")
+	assert.True(t, ok)
+	_, ok = c.Get("k3")
+	assert.True(t, ok)
+	assert.Equal(t, ev+1, testutil.ToFloat64(totalEvictions.WithLabelValues(typeMemory)))
+}
+
+func TestLRU_Update(t *testing.T) {
+	c, _ := NewLRU(2, 1000000)
+	c.Add("k1", &api.Analysis{})
+	v := &api.Analysis{Tags: &api.TaggerResult{}}
+	c.Add("k1", v)
+	assert.Equal(t, 1, c.Len())
+	r, _ := c.Get("k1")
+	assert.Equal(t, v, r)
+}
+
+func TestLRU_EvictsByBytes(t *testing.T) {
+	v := &api.Analysis{Segments: &api.SegmenterResult{Seg: [][]int{{0, 1}, {2, 1}}}}
+	b := approxSize("k1", v)
+	c, _ := NewLRU(10, 2*b)
+	c.Add("k1", v)
+	c.Add("k2", v)
+	assert.Equal(t, 2*b, c.Bytes())
+	c.Add("k3", v)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, 2*b, c.Bytes())
+	_, ok := c.Get("k1")
+	assert.False(t, ok)
+}
+
+func TestLRU_SkipsLarge(t *testing.T) {
+	c, _ := NewLRU(10, 200)
+	c.Add("k1", &api.Analysis{})
+	c.Add("k2", &api.Analysis{Segments: &api.SegmenterResult{Seg: make([][]int, 10)}})
+	_, ok := c.Get("k2")
+	assert.False(t, ok)
+	_, ok = c.Get("k1")
+	assert.True(t, ok)
+	c.Add("k1", &api.Analysis{Segments: &api.SegmenterResult{Seg: make([][]int, 10)}})
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, 0, c.Bytes())
+}
+
+func TestApproxSize(t *testing.T) {
+	assert.Equal(t, 26, approxSize("ab", nil))
+	assert.Equal(t, 26+3*24+24+16, approxSize("ab", &api.Analysis{Segments: &api.SegmenterResult{Seg: [][]int{{0, 1}}}}))
+	assert.Equal(t, 26+24+24+24+(24+1)+(24+2)+24+(24+3),
+		approxSize("ab", &api.Analysis{Tags: &api.TaggerResult{Msd: [][][]string{{{"a", "bb"}}}, Stem: []string{"ccc"}}}))
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	typeMemory = "memory"
+	typeDisk   = "disk"
+)
+
+var totalHits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "cache_hits_total",
+	Help:      "The total number of texts found in the cache",
+}, []string{"type"})
+
+var totalMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "cache_misses_total",
+	Help:      "The total number of texts not found in the cache",
+}, []string{"type"})
+
+var totalEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "cache_evictions_total",
+	Help:      "The total number of items dropped from the cache",
+}, []string{"type"})
+
+var totalDroppedWrites = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "cache_dropped_writes_total",
+	Help:      "The total number of items not written to the disk cache as the write queue is full",
+})
@@ -0,0 +1,226 @@
+package chunking
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+type (
+	segmenter interface {
+		Process(ctx context.Context, text string) (*api.SegmenterResult, error)
+	}
+
+	tagger interface {
+		Process(context.Context, string, *api.SegmenterResult) (*api.TaggerResult, error)
+	}
+)
+
+//Segmenter splits long texts into chunks and segments them in parallel
+type Segmenter struct {
+	real    segmenter
+	size    int
+	workers int
+}
+
+//NewSegmenter creates a chunking segmenter.
+//size is the max chunk length in runes, workers - the max count of parallel calls to the real segmenter
+func NewSegmenter(real segmenter, size, workers int) (*Segmenter, error) {
+	if real == nil {
+		return nil, errors.New("no segmenter")
+	}
+	if err := validate(size, workers); err != nil {
+		return nil, err
+	}
+	return &Segmenter{real: real, size: size, workers: workers}, nil
+}
+
+//Process segments the text, the results of chunks are joined with the shifted offsets
+func (s *Segmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	if len([]rune(text)) <= s.size {
+		return s.real.Process(ctx, text)
+	}
+	chunks := splitText(text, s.size)
+	results := make([]*api.SegmenterResult, len(chunks))
+	err := runParallel(ctx, len(chunks), s.workers, func(ctx context.Context, i int) error {
+		var err error
+		results[i], err = s.real.Process(ctx, chunks[i].text)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &api.SegmenterResult{}
+	for i, r := range results {
+		if r == nil {
+			return nil, errors.Errorf("no segmentation result for chunk %d", i)
+		}
+		// the paragraph and the sentence continue in the next chunk if the cut is not at their end
+		joinP, joinS := false, false
+		if i > 0 {
+			joinP, joinS = joins(chunks[i-1], chunks[i])
+		}
+		res.Seg = append(res.Seg, utils.ShiftSpans(r.Seg, chunks[i].offset)...)
+		res.S = appendSpans(res.S, utils.ShiftSpans(r.S, chunks[i].offset), joinS)
+		res.P = appendSpans(res.P, utils.ShiftSpans(r.P, chunks[i].offset), joinP)
+	}
+	return res, nil
+}
+
+//joins checks if the paragraph and the sentence continue over the cut between the prev and the next chunk.
+//The paragraph ends if there is a new line at the cut, the sentence ends also if prev ends with a sentence
+func joins(prev, next chunk) (bool, bool) {
+	gap := prev.text[len(strings.TrimRightFunc(prev.text, unicode.IsSpace)):] +
+		next.text[:len(next.text)-len(strings.TrimLeftFunc(next.text, unicode.IsSpace))]
+	if strings.Contains(gap, "\n") {
+		return false, false
+	}
+	return true, !prev.sentenceEnd
+}
+
+//appendSpans appends spans to res, the last span of res and the first of spans are merged into one if join is set
+func appendSpans(res, spans [][]int, join bool) [][]int {
+	if join && len(res) > 0 && len(spans) > 0 && len(res[len(res)-1]) > 1 && len(spans[0]) > 1 {
+		last, first := res[len(res)-1], spans[0]
+		res[len(res)-1] = []int{last[0], first[0] + first[1] - last[0]}
+		spans = spans[1:]
+	}
+	return append(res, spans...)
+}
+
+//Tagger splits long texts into chunks at the sentence boundaries and tags them in parallel
+type Tagger struct {
+	real    tagger
+	size    int
+	workers int
+}
+
+//NewTagger creates a chunking tagger.
+//size is the max chunk length in runes, workers - the max count of parallel calls to the real tagger
+func NewTagger(real tagger, size, workers int) (*Tagger, error) {
+	if real == nil {
+		return nil, errors.New("no tagger")
+	}
+	if err := validate(size, workers); err != nil {
+		return nil, err
+	}
+	return &Tagger{real: real, size: size, workers: workers}, nil
+}
+
+//Process tags the text, the results of chunks are joined
+func (t *Tagger) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	rns := []rune(text)
+	if len(rns) <= t.size || data == nil {
+		return t.real.Process(ctx, text, data)
+	}
+	bounds := sentenceBounds(data, len(rns), t.size)
+	if len(bounds) < 3 {
+		return t.real.Process(ctx, text, data)
+	}
+	results := make([]*api.TaggerResult, len(bounds)-1)
+	err := runParallel(ctx, len(results), t.workers, func(ctx context.Context, i int) error {
+		from, to := bounds[i], bounds[i+1]
+		var err error
+		results[i], err = t.real.Process(ctx, string(rns[from:to]), cut(data, from, to))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &api.TaggerResult{}
+	for i, r := range results {
+		if r == nil {
+			return nil, errors.Errorf("no tagger result for chunk %d", i)
+		}
+		res.Msd = append(res.Msd, r.Msd...)
+		res.Stem = append(res.Stem, r.Stem...)
+	}
+	return res, nil
+}
+
+//sentenceBounds returns chunk boundaries: 0, sentence starts..., l.
+//A boundary is never set inside a segment
+func sentenceBounds(data *api.SegmenterResult, l, size int) []int {
+	res := []int{0}
+	for _, s := range data.S {
+		if len(s) < 2 || s[0] <= res[len(res)-1] {
+			continue
+		}
+		if s[0]+s[1]-res[len(res)-1] > size && !insideSegment(data.Seg, s[0]) {
+			res = append(res, s[0])
+		}
+	}
+	return append(res, l)
+}
+
+func insideSegment(seg [][]int, pos int) bool {
+	for _, s := range seg {
+		if len(s) > 1 && s[0] < pos && pos < s[0]+s[1] {
+			return true
+		}
+	}
+	return false
+}
+
+//cut returns the segmentation data in [from, to) shifted to from
+func cut(data *api.SegmenterResult, from, to int) *api.SegmenterResult {
+	return &api.SegmenterResult{Seg: utils.CutSpans(data.Seg, from, to), S: utils.CutSpans(data.S, from, to),
+		P: utils.CutSpans(data.P, from, to)}
+}
+
+//runParallel calls f for [0, n) with at most workers goroutines, returns the first error.
+//The calls are canceled and the rest are skipped after the first error or if ctx is done
+func runParallel(ctx context.Context, n, workers int, f func(ctx context.Context, i int) error) error {
+	ctx, cancelF := context.WithCancel(ctx)
+	defer cancelF()
+	workC := make(chan int)
+	errC := make(chan error, n+1)
+	wg := sync.WaitGroup{}
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range workC {
+				if err := f(ctx, i); err != nil {
+					errC <- err
+					cancelF()
+				}
+			}
+		}()
+	}
+loop:
+	for i := 0; i < n; i++ {
+		select {
+		case workC <- i:
+		case <-ctx.Done():
+			errC <- ctx.Err()
+			break loop
+		}
+	}
+	close(workC)
+	wg.Wait()
+	close(errC)
+	return <-errC
+}
+
+func validate(size, workers int) error {
+	if size < 1 {
+		return errors.Errorf("wrong chunk size %d", size)
+	}
+	if workers < 1 {
+		return errors.Errorf("wrong workers count %d", workers)
+	}
+	return nil
+}
+
+func min(i1, i2 int) int {
+	if i1 > i2 {
+		return i2
+	}
+	return i1
+}
@@ -0,0 +1,274 @@
+package chunking
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/tokenizer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		text string
+		size int
+		exp  []chunk
+	}{
+		{text: "olia", size: 10, exp: []chunk{{0, "olia", true}}},
+		{text: "aa bb\ncc dd", size: 8, exp: []chunk{{0, "aa bb\n", true}, {6, "cc dd", true}}},
+		{text: "aa. Bb cc. dd", size: 9, exp: []chunk{{0, "aa. ", true}, {4, "Bb cc. dd", true}}},
+		{text: "aa bb cc", size: 4, exp: []chunk{{0, "aa ", false}, {3, "bb ", false}, {6, "cc", true}}},
+		{text: "aaaaa", size: 2, exp: []chunk{{0, "aaaaa", true}}},
+		{text: "aaaaa bb cc", size: 2, exp: []chunk{{0, "aaaaa ", false}, {6, "bb ", false}, {9, "cc", true}}},
+		{text: "ąčęėįšų ūž", size: 8, exp: []chunk{{0, "ąčęėįšų ", false}, {8, "ūž", true}}},
+		{text: "Gimė 1990 m. sausio", size: 14, exp: []chunk{{0, "Gimė 1990 m. ", false}, {13, "sausio", true}}},
+		{text: "Tai A. Vaičiūnas. Jis", size: 20, exp: []chunk{{0, "Tai A. Vaičiūnas. ", true}, {18, "Jis", true}}},
+	}
+
+	for i, tc := range tests {
+		assert.Equal(t, tc.exp, splitText(tc.text, tc.size), "fail %d", i)
+	}
+}
+
+func TestNew_Fail(t *testing.T) {
+	_, err := NewSegmenter(nil, 10, 1)
+	assert.NotNil(t, err)
+	_, err = NewSegmenter(&testSegmenter{}, 0, 1)
+	assert.NotNil(t, err)
+	_, err = NewSegmenter(&testSegmenter{}, 10, 0)
+	assert.NotNil(t, err)
+	_, err = NewTagger(nil, 10, 1)
+	assert.NotNil(t, err)
+	_, err = NewTagger(&testTagger{}, 0, 1)
+	assert.NotNil(t, err)
+}
+
+func TestSegmenter_Short(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, 10, 2)
+	r, err := s.Process(context.Background(), "aa bb")
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 2}, {3, 2}}, r.Seg)
+	assert.Equal(t, []string{"aa bb"}, ts.texts)
+}
+
+func TestSegmenter_Chunks(t *testing.T) {
+	ts := &testSegmenter{}
+	s, _ := NewSegmenter(ts, 6, 2)
+	r, err := s.Process(context.Background(), "aa bb\ncc dd\nee")
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 2}, {3, 2}, {6, 2}, {9, 2}, {12, 2}}, r.Seg)
+	assert.Equal(t, [][]int{{0, 6}, {6, 6}, {12, 2}}, r.S)
+	assert.Equal(t, [][]int{{0, 6}, {6, 6}, {12, 2}}, r.P)
+	assert.ElementsMatch(t, []string{"aa bb\n", "cc dd\n", "ee"}, ts.texts)
+}
+
+func TestSegmenter_JoinsSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "sentence cuts", text: strings.Repeat("Mama su kasa kasa smėlį. ", 160) + "\nAntra pastraipa."},
+		{name: "space cuts", text: strings.Repeat("mama su kasa kasa smėlį ", 160) + "\nantra pastraipa"},
+		{name: "paragraph cuts", text: strings.Repeat("Mama su kasa.\n", 300)},
+		{name: "abbreviation cuts", text: strings.Repeat("Jis gimė 1990 m. sausio 5 d. Vilniuje, žr. A. Vaičiūno pan. knygas. ", 60)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &tokenizerSegmenter{}
+			exp, _ := ts.Process(context.Background(), tc.text)
+			s, _ := NewSegmenter(ts, 500, 2)
+			r, err := s.Process(context.Background(), tc.text)
+			require.Nil(t, err)
+			assert.Equal(t, exp.Seg, r.Seg)
+			assert.Equal(t, exp.S, r.S)
+			assert.Equal(t, exp.P, r.P)
+		})
+	}
+}
+
+func TestSegmenter_AbbreviationAtCut(t *testing.T) {
+	text := "Jis gimė 1990 m. sausio penktą dieną Vilniuje. Aš ne, žr. A. Vaičiūno pan. knygas. Taip."
+	ts := &tokenizerSegmenter{}
+	exp, _ := ts.Process(context.Background(), text)
+	for size := 10; size < len([]rune(text)); size++ {
+		s, _ := NewSegmenter(ts, size, 2)
+		r, err := s.Process(context.Background(), text)
+		require.Nil(t, err)
+		assert.Equal(t, exp, r, "fail size %d", size)
+	}
+}
+
+func TestJoins(t *testing.T) {
+	tests := []struct {
+		prev, next   chunk
+		joinP, joinS bool
+	}{
+		{prev: chunk{text: "aa bb\n", sentenceEnd: true}, next: chunk{text: "cc"}, joinP: false, joinS: false},
+		{prev: chunk{text: "aa bb "}, next: chunk{text: "\ncc"}, joinP: false, joinS: false},
+		{prev: chunk{text: "aa bb. ", sentenceEnd: true}, next: chunk{text: "Cc"}, joinP: true, joinS: false},
+		{prev: chunk{text: "aa m. "}, next: chunk{text: "sausio"}, joinP: true, joinS: true},
+		{prev: chunk{text: "aa bb "}, next: chunk{text: "cc"}, joinP: true, joinS: true},
+	}
+	for i, tc := range tests {
+		p, s := joins(tc.prev, tc.next)
+		assert.Equal(t, tc.joinP, p, "fail %d", i)
+		assert.Equal(t, tc.joinS, s, "fail %d", i)
+	}
+}
+
+func TestAppendSpans(t *testing.T) {
+	assert.Equal(t, [][]int{{0, 2}, {3, 2}}, appendSpans([][]int{{0, 2}}, [][]int{{3, 2}}, false))
+	assert.Equal(t, [][]int{{0, 5}, {6, 1}}, appendSpans([][]int{{0, 2}}, [][]int{{3, 2}, {6, 1}}, true))
+	assert.Equal(t, [][]int{{3, 2}}, appendSpans(nil, [][]int{{3, 2}}, true))
+}
+
+func TestSegmenter_Fail(t *testing.T) {
+	ts := &testSegmenter{err: errors.New("olia")}
+	s, _ := NewSegmenter(ts, 6, 2)
+	_, err := s.Process(context.Background(), "aa bb\ncc dd\nee")
+	assert.NotNil(t, err)
+}
+
+func TestTagger_Short(t *testing.T) {
+	tt := &testTagger{}
+	tg, _ := NewTagger(tt, 100, 2)
+	text := "aa bb\ncc dd"
+	r, err := tg.Process(context.Background(), text, segment(text))
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(r.Msd))
+	assert.Equal(t, 1, len(tt.texts))
+}
+
+func TestTagger_Chunks(t *testing.T) {
+	tt := &testTagger{}
+	tg, _ := NewTagger(tt, 6, 2)
+	text := "aa bb\ncc dd\nee"
+	r, err := tg.Process(context.Background(), text, segment(text))
+	require.Nil(t, err)
+	assert.Equal(t, [][][]string{{{"aa", "X-"}}, {{"bb", "X-"}}, {{"cc", "X-"}}, {{"dd", "X-"}}, {{"ee", "X-"}}},
+		r.Msd)
+	assert.ElementsMatch(t, []string{"aa bb\n", "cc dd\n", "ee"}, tt.texts)
+}
+
+func TestTagger_Fail(t *testing.T) {
+	tt := &testTagger{err: errors.New("olia")}
+	tg, _ := NewTagger(tt, 6, 2)
+	text := "aa bb\ncc dd\nee"
+	_, err := tg.Process(context.Background(), text, segment(text))
+	assert.NotNil(t, err)
+}
+
+func TestRunParallel(t *testing.T) {
+	mu := sync.Mutex{}
+	called := make(map[int]bool)
+	err := runParallel(context.Background(), 10, 3, func(ctx context.Context, i int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		called[i] = true
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(called))
+	err = runParallel(context.Background(), 10, 3, func(ctx context.Context, i int) error {
+		if i == 5 {
+			return errors.New("olia")
+		}
+		return nil
+	})
+	assert.NotNil(t, err)
+}
+
+func TestRunParallel_Canceled(t *testing.T) {
+	ctx, cancelF := context.WithCancel(context.Background())
+	var called int32
+	err := runParallel(ctx, 10, 1, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&called, 1)
+		cancelF()
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Less(t, atomic.LoadInt32(&called), int32(10))
+}
+
+func TestRunParallel_CancelsOnError(t *testing.T) {
+	err := runParallel(context.Background(), 2, 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			return errors.New("olia")
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.NotNil(t, err)
+}
+
+type testSegmenter struct {
+	mu    sync.Mutex
+	texts []string
+	err   error
+}
+
+func (s *testSegmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	s.mu.Lock()
+	s.texts = append(s.texts, text)
+	s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	return segment(text), nil
+}
+
+type testTagger struct {
+	mu    sync.Mutex
+	texts []string
+	err   error
+}
+
+func (s *testTagger) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	s.mu.Lock()
+	s.texts = append(s.texts, text)
+	s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	rns := []rune(text)
+	res := &api.TaggerResult{}
+	for _, s := range data.Seg {
+		res.Msd = append(res.Msd, [][]string{{string(rns[s[0] : s[0]+s[1]]), "X-"}})
+	}
+	return res, nil
+}
+
+//segment makes a word per single space separated token, a sentence and a paragraph per line
+func segment(text string) *api.SegmenterResult {
+	res := &api.SegmenterResult{}
+	pos := 0
+	for _, l := range strings.SplitAfter(text, "\n") {
+		ll := len([]rune(l))
+		if ll == 0 {
+			continue
+		}
+		res.S = append(res.S, []int{pos, ll})
+		res.P = append(res.P, []int{pos, ll})
+		wp := pos
+		for _, w := range strings.Fields(l) {
+			wl := len([]rune(w))
+			res.Seg = append(res.Seg, []int{wp, wl})
+			wp += wl + 1
+		}
+		pos += ll
+	}
+	return res
+}
+
+type tokenizerSegmenter struct{}
+
+func (s *tokenizerSegmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	return tokenizer.Segment(text), nil
+}
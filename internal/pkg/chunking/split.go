@@ -0,0 +1,88 @@
+package chunking
+
+import (
+	"unicode"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/tokenizer"
+)
+
+type chunk struct {
+	offset int
+	text   string
+	// sentenceEnd is set if a sentence ends at the end of the chunk
+	sentenceEnd bool
+}
+
+//splitText splits the text into chunks of max size runes.
+//It cuts after a new line, after a sentence end or after a space in the order of preference.
+//The sentence ends are taken from the local tokenizer, so abbreviations and initials do not end a sentence.
+//A chunk is extended to the next space if there is no space to cut at
+func splitText(text string, size int) []chunk {
+	rns := []rune(text)
+	res := make([]chunk, 0, len(rns)/size+1)
+	var ends []bool
+	if len(rns) > size {
+		ends = sentenceEnds(text, len(rns))
+	}
+	pos := 0
+	for len(rns)-pos > size {
+		cut := findCut(rns, ends, pos, pos+size)
+		res = append(res, chunk{offset: pos, text: string(rns[pos:cut]), sentenceEnd: isEndBefore(rns, ends, cut)})
+		pos = cut
+	}
+	if pos < len(rns) {
+		res = append(res, chunk{offset: pos, text: string(rns[pos:]), sentenceEnd: true})
+	}
+	return res
+}
+
+//sentenceEnds marks the positions where the sentences of the text end
+func sentenceEnds(text string, l int) []bool {
+	res := make([]bool, l+1)
+	for _, s := range tokenizer.Segment(text).S {
+		if len(s) > 1 && s[0]+s[1] <= l {
+			res[s[0]+s[1]] = true
+		}
+	}
+	return res
+}
+
+var cutRules = []func(rns []rune, ends []bool, i int) bool{isParagraphEnd, isSentenceEnd, isSpaceEnd}
+
+//findCut returns the position in (from, to] or the position after the next space if there is no place to cut
+func findCut(rns []rune, ends []bool, from, to int) int {
+	for _, f := range cutRules {
+		for i := to; i > from; i-- {
+			if f(rns, ends, i) {
+				return i
+			}
+		}
+	}
+	// do not split a word
+	for i := to + 1; i < len(rns); i++ {
+		if isSpaceEnd(rns, ends, i) {
+			return i
+		}
+	}
+	return len(rns)
+}
+
+func isParagraphEnd(rns []rune, ends []bool, i int) bool {
+	return rns[i-1] == '\n'
+}
+
+func isSentenceEnd(rns []rune, ends []bool, i int) bool {
+	return unicode.IsSpace(rns[i-1]) && isEndBefore(rns, ends, i)
+}
+
+func isSpaceEnd(rns []rune, ends []bool, i int) bool {
+	return unicode.IsSpace(rns[i-1])
+}
+
+//isEndBefore checks if a sentence ends before the spaces at i
+func isEndBefore(rns []rune, ends []bool, i int) bool {
+	for i > 0 && unicode.IsSpace(rns[i-1]) {
+		i--
+	}
+	return i < len(ends) && ends[i]
+}
@@ -0,0 +1,90 @@
+package coalescing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/pkg/errors"
+)
+
+type (
+	segmenter interface {
+		Process(ctx context.Context, text string) (*api.SegmenterResult, error)
+	}
+
+	tagger interface {
+		Process(context.Context, string, *api.SegmenterResult) (*api.TaggerResult, error)
+	}
+)
+
+//Segmenter shares one segmenter call for the same texts processed at the same time
+type Segmenter struct {
+	real segmenter
+	g    group
+}
+
+//NewSegmenter creates a coalescing segmenter
+func NewSegmenter(real segmenter) (*Segmenter, error) {
+	if real == nil {
+		return nil, errors.New("no segmenter")
+	}
+	return &Segmenter{real: real}, nil
+}
+
+//Process segments the text or waits for the running call with the same text
+func (s *Segmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	res, err, shared := s.g.do(ctx, hash([]byte(text)), func(ctx context.Context) (interface{}, error) {
+		return s.real.Process(ctx, text)
+	})
+	if shared {
+		totalSaved.WithLabelValues("segmenter").Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.(*api.SegmenterResult), nil
+}
+
+//Tagger shares one tagger call for the same texts processed at the same time
+type Tagger struct {
+	real tagger
+	g    group
+}
+
+//NewTagger creates a coalescing tagger
+func NewTagger(real tagger) (*Tagger, error) {
+	if real == nil {
+		return nil, errors.New("no tagger")
+	}
+	return &Tagger{real: real}, nil
+}
+
+//Process tags the text or waits for the running call with the same text and segmentation
+func (t *Tagger) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	sd, err := json.Marshal(data)
+	if err != nil {
+		return t.real.Process(ctx, text, data)
+	}
+	res, err, shared := t.g.do(ctx, hash([]byte(text), sd), func(ctx context.Context) (interface{}, error) {
+		return t.real.Process(ctx, text, data)
+	})
+	if shared {
+		totalSaved.WithLabelValues("tagger").Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.(*api.TaggerResult), nil
+}
+
+func hash(data ...[]byte) string {
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,216 @@
+package coalescing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Fail(t *testing.T) {
+	_, err := NewSegmenter(nil)
+	assert.NotNil(t, err)
+	_, err = NewTagger(nil)
+	assert.NotNil(t, err)
+}
+
+func TestSegmenter_Shares(t *testing.T) {
+	saved := testutil.ToFloat64(totalSaved.WithLabelValues("segmenter"))
+	ts := &testSegmenter{wait: 50 * time.Millisecond, res: &api.SegmenterResult{Seg: [][]int{{0, 2}}}}
+	s, _ := NewSegmenter(ts)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := s.Process(context.Background(), "aa")
+			assert.Nil(t, err)
+			assert.Equal(t, ts.res, r)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), ts.calls)
+	assert.Equal(t, saved+2, testutil.ToFloat64(totalSaved.WithLabelValues("segmenter")))
+}
+
+func TestSegmenter_Priority(t *testing.T) {
+	ts := &testSegmenter{res: &api.SegmenterResult{}}
+	s, _ := NewSegmenter(ts)
+	_, err := s.Process(backend.WithPriority(context.Background(), backend.PriorityHigh), "aa")
+	assert.Nil(t, err)
+	assert.Equal(t, int32(backend.PriorityHigh), atomic.LoadInt32(&ts.priority))
+}
+
+func TestSegmenter_NotSharedAcrossPriorities(t *testing.T) {
+	ts := &testSegmenter{wait: 50 * time.Millisecond, res: &api.SegmenterResult{}}
+	s, _ := NewSegmenter(ts)
+	wg := sync.WaitGroup{}
+	for _, p := range []backend.Priority{backend.PriorityLow, backend.PriorityHigh, backend.PriorityHigh} {
+		wg.Add(1)
+		go func(p backend.Priority) {
+			defer wg.Done()
+			_, err := s.Process(backend.WithPriority(context.Background(), p), "aa")
+			assert.Nil(t, err)
+		}(p)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&ts.calls))
+}
+
+func TestSegmenter_Different(t *testing.T) {
+	ts := &testSegmenter{wait: 50 * time.Millisecond, res: &api.SegmenterResult{}}
+	s, _ := NewSegmenter(ts)
+	wg := sync.WaitGroup{}
+	for _, txt := range []string{"aa", "bb"} {
+		wg.Add(1)
+		go func(txt string) {
+			defer wg.Done()
+			_, err := s.Process(context.Background(), txt)
+			assert.Nil(t, err)
+		}(txt)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), ts.calls)
+}
+
+func TestSegmenter_Sequential(t *testing.T) {
+	ts := &testSegmenter{res: &api.SegmenterResult{}}
+	s, _ := NewSegmenter(ts)
+	_, _ = s.Process(context.Background(), "aa")
+	_, _ = s.Process(context.Background(), "aa")
+	assert.Equal(t, int32(2), ts.calls)
+}
+
+func TestSegmenter_SharesError(t *testing.T) {
+	ts := &testSegmenter{wait: 50 * time.Millisecond, err: errors.New("olia")}
+	s, _ := NewSegmenter(ts)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Process(context.Background(), "aa")
+			assert.NotNil(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), ts.calls)
+}
+
+func TestSegmenter_Leave(t *testing.T) {
+	ts := &testSegmenter{wait: time.Second, res: &api.SegmenterResult{}}
+	s, _ := NewSegmenter(ts)
+	ctx, cancelF := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Process(ctx, "aa")
+			assert.Equal(t, context.Canceled, err)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancelF()
+	wg.Wait()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&ts.canceled))
+}
+
+func TestSegmenter_LeaveOne(t *testing.T) {
+	ts := &testSegmenter{wait: 100 * time.Millisecond, res: &api.SegmenterResult{}}
+	s, _ := NewSegmenter(ts)
+	ctx, cancelF := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, err := s.Process(ctx, "aa")
+		assert.Equal(t, context.Canceled, err)
+	}()
+	go func() {
+		defer wg.Done()
+		r, err := s.Process(context.Background(), "aa")
+		assert.Nil(t, err)
+		assert.Equal(t, ts.res, r)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancelF()
+	wg.Wait()
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ts.canceled))
+}
+
+func TestTagger_Shares(t *testing.T) {
+	tt := &testTagger{wait: 50 * time.Millisecond, res: &api.TaggerResult{Msd: [][][]string{{{"aa", "X-"}}}}}
+	tg, _ := NewTagger(tt)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := tg.Process(context.Background(), "aa", &api.SegmenterResult{Seg: [][]int{{0, 2}}})
+			assert.Nil(t, err)
+			require.NotNil(t, r)
+			assert.Equal(t, tt.res, r)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), tt.calls)
+}
+
+func TestTagger_DifferentSegments(t *testing.T) {
+	tt := &testTagger{wait: 50 * time.Millisecond, res: &api.TaggerResult{}}
+	tg, _ := NewTagger(tt)
+	wg := sync.WaitGroup{}
+	for i := 1; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := tg.Process(context.Background(), "aa", &api.SegmenterResult{Seg: [][]int{{0, i}}})
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), tt.calls)
+}
+
+type testSegmenter struct {
+	calls    int32
+	canceled int32
+	priority int32
+	wait     time.Duration
+	res      *api.SegmenterResult
+	err      error
+}
+
+func (s *testSegmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	atomic.AddInt32(&s.calls, 1)
+	atomic.StoreInt32(&s.priority, int32(backend.PriorityFrom(ctx)))
+	select {
+	case <-time.After(s.wait):
+	case <-ctx.Done():
+		atomic.AddInt32(&s.canceled, 1)
+		return nil, ctx.Err()
+	}
+	return s.res, s.err
+}
+
+type testTagger struct {
+	calls int32
+	wait  time.Duration
+	res   *api.TaggerResult
+}
+
+func (s *testTagger) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	atomic.AddInt32(&s.calls, 1)
+	time.Sleep(s.wait)
+	return s.res, nil
+}
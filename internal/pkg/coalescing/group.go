@@ -0,0 +1,80 @@
+package coalescing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+)
+
+type call struct {
+	done chan struct{}
+	res  interface{}
+	err  error
+	// waiting is the count of callers waiting for the result, ctx is canceled when all callers leave
+	waiting int
+	ctx     context.Context
+	cancelF context.CancelFunc
+}
+
+//group runs one call for the same key at a time, the concurrent callers get the result of the running call
+type group struct {
+	lock  sync.Mutex
+	calls map[string]*call
+}
+
+//do runs f or waits for the running call with the same key and priority. Returns true if the result is shared.
+//The call is canceled only if all callers leave
+func (g *group) do(ctx context.Context, key string, f func(ctx context.Context) (interface{}, error)) (interface{}, error, bool) {
+	// calls are not shared across priorities, a low priority call must not delay a high priority caller
+	pr := backend.PriorityFrom(ctx)
+	key = pr.String() + ":" + key
+	g.lock.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, shared := g.calls[key]
+	if !shared {
+		c = &call{done: make(chan struct{})}
+		c.ctx, c.cancelF = context.WithCancel(backend.WithPriority(context.Background(), pr))
+		g.calls[key] = c
+		go g.run(key, c, f)
+	}
+	c.waiting++
+	g.lock.Unlock()
+
+	select {
+	case <-c.done:
+		return c.res, c.err, shared
+	case <-ctx.Done():
+		g.leave(key, c)
+		return nil, ctx.Err(), false
+	}
+}
+
+func (g *group) run(key string, c *call, f func(ctx context.Context) (interface{}, error)) {
+	defer c.cancelF()
+	c.res, c.err = f(c.ctx)
+
+	g.lock.Lock()
+	g.remove(key, c)
+	g.lock.Unlock()
+	close(c.done)
+}
+
+func (g *group) leave(key string, c *call) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	c.waiting--
+	if c.waiting == 0 {
+		// new callers must not join the canceled call
+		g.remove(key, c)
+		c.cancelF()
+	}
+}
+
+func (g *group) remove(key string, c *call) {
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,12 @@
+package coalescing
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var totalSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "coalesced_calls_total",
+	Help:      "The total number of backend calls saved by sharing the result of the same running call",
+}, []string{"backend"})
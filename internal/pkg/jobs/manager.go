@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+//Status is a job status
+type Status string
+
+const (
+	//StatusWaiting - job is in the queue
+	StatusWaiting Status = "WAITING"
+	//StatusWorking - job is being processed
+	StatusWorking Status = "WORKING"
+	//StatusDone - job finished successfully
+	StatusDone Status = "DONE"
+	//StatusFailed - job failed
+	StatusFailed Status = "FAILED"
+)
+
+//Work is a job function. It may report the processing stage with the progress func.
+//ctx is canceled when the manager is closed
+type Work func(ctx context.Context, progress func(stage string)) (interface{}, error)
+
+//Info is the job status info
+type Info struct {
+	ID       string     `json:"id"`
+	Status   Status     `json:"status"`
+	Stage    string     `json:"stage,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Started  *time.Time `json:"started,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	//Expires is the time the job is removed from the manager
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+type job struct {
+	info   Info
+	work   Work
+	result interface{}
+}
+
+//Manager runs jobs in the background and keeps the results in memory
+type Manager struct {
+	lock    sync.Mutex
+	jobs    map[string]*job
+	queue   chan *job
+	maxJobs int
+	ttl     time.Duration
+
+	ctx     context.Context
+	cancelF context.CancelFunc
+	closeC  chan struct{}
+	wg      sync.WaitGroup
+}
+
+//NewManager creates the job manager and starts the workers.
+//maxJobs limits the count of waiting, working and finished jobs kept in memory,
+//ttl is the time the finished job is kept
+func NewManager(workers, maxJobs int, ttl time.Duration) (*Manager, error) {
+	if workers < 1 {
+		return nil, errors.Errorf("wrong workers count %d", workers)
+	}
+	if maxJobs < 1 {
+		return nil, errors.Errorf("wrong max jobs %d", maxJobs)
+	}
+	if ttl <= 0 {
+		return nil, errors.Errorf("wrong ttl %v", ttl)
+	}
+	res := &Manager{jobs: make(map[string]*job), queue: make(chan *job, maxJobs), maxJobs: maxJobs, ttl: ttl,
+		closeC: make(chan struct{})}
+	res.ctx, res.cancelF = context.WithCancel(context.Background())
+	for i := 0; i < workers; i++ {
+		res.wg.Add(1)
+		go res.runWorker()
+	}
+	res.wg.Add(1)
+	go res.runCleaner(minDuration(ttl, time.Minute))
+	return res, nil
+}
+
+//Add adds the job to the queue, returns utils.ErrTooBusy if the manager is full
+func (m *Manager) Add(w Work) (*Info, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.removeExpired(time.Now())
+	if len(m.jobs) >= m.maxJobs && !m.removeOldestFinished() {
+		return nil, utils.ErrTooBusy
+	}
+	j := &job{info: Info{ID: id, Status: StatusWaiting, Created: time.Now()}, work: w}
+	m.jobs[id] = j
+	m.queue <- j
+	info := j.info
+	return &info, nil
+}
+
+//Get returns the job info and the result
+func (m *Manager) Get(id string) (*Info, interface{}, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok || isExpired(j, time.Now()) {
+		return nil, nil, false
+	}
+	info := j.info
+	return &info, j.result, true
+}
+
+//Close stops the workers, cancels the running jobs and waits for them to finish
+func (m *Manager) Close() {
+	close(m.closeC)
+	m.cancelF()
+	m.wg.Wait()
+}
+
+func (m *Manager) runWorker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.closeC:
+			return
+		case j := <-m.queue:
+			m.run(j)
+		}
+	}
+}
+
+func (m *Manager) run(j *job) {
+	m.update(j, func() {
+		now := time.Now()
+		j.info.Status, j.info.Started = StatusWorking, &now
+	})
+	res, err := j.work(m.ctx, func(stage string) {
+		m.update(j, func() { j.info.Stage = stage })
+	})
+	if err != nil {
+		goapp.Log.Warnf("job %s failed: %v", j.info.ID, err)
+	}
+	m.update(j, func() {
+		now := time.Now()
+		expires := now.Add(m.ttl)
+		j.info.Finished, j.info.Expires, j.info.Stage = &now, &expires, ""
+		if err != nil {
+			j.info.Status, j.info.Error = StatusFailed, err.Error()
+		} else {
+			j.info.Status, j.result = StatusDone, res
+		}
+		j.work = nil
+	})
+}
+
+func (m *Manager) update(j *job, f func()) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	f()
+}
+
+func (m *Manager) runCleaner(every time.Duration) {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.closeC:
+			return
+		case <-time.After(every):
+			m.lock.Lock()
+			m.removeExpired(time.Now())
+			m.lock.Unlock()
+		}
+	}
+}
+
+func (m *Manager) removeExpired(now time.Time) {
+	for id, j := range m.jobs {
+		if isExpired(j, now) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func (m *Manager) removeOldestFinished() bool {
+	var oldest *job
+	for _, j := range m.jobs {
+		if j.info.Finished != nil && (oldest == nil || j.info.Finished.Before(*oldest.info.Finished)) {
+			oldest = j
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	delete(m.jobs, oldest.info.ID)
+	return true
+}
+
+func isExpired(j *job, now time.Time) bool {
+	return j.info.Expires != nil && !now.Before(*j.info.Expires)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "can't generate id")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func minDuration(d1, d2 time.Duration) time.Duration {
+	if d1 < d2 {
+		return d1
+	}
+	return d2
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	m, err := NewManager(1, 10, time.Minute)
+	assert.Nil(t, err)
+	require.NotNil(t, m)
+	m.Close()
+}
+
+func TestNew_Fail(t *testing.T) {
+	_, err := NewManager(0, 10, time.Minute)
+	assert.NotNil(t, err)
+	_, err = NewManager(1, 0, time.Minute)
+	assert.NotNil(t, err)
+	_, err = NewManager(1, 10, 0)
+	assert.NotNil(t, err)
+}
+
+func TestAdd(t *testing.T) {
+	m, _ := NewManager(1, 10, time.Minute)
+	defer m.Close()
+	info, err := m.Add(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		progress("working")
+		return "olia", nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, StatusWaiting, info.Status)
+	assert.NotEmpty(t, info.ID)
+
+	info = waitFinished(t, m, info.ID)
+	assert.Equal(t, StatusDone, info.Status)
+	assert.NotNil(t, info.Started)
+	assert.NotNil(t, info.Expires)
+	_, res, ok := m.Get(info.ID)
+	assert.True(t, ok)
+	assert.Equal(t, "olia", res)
+}
+
+func TestAdd_Fails(t *testing.T) {
+	m, _ := NewManager(1, 10, time.Minute)
+	defer m.Close()
+	info, err := m.Add(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		return nil, errors.New("olia")
+	})
+	require.Nil(t, err)
+
+	info = waitFinished(t, m, info.ID)
+	assert.Equal(t, StatusFailed, info.Status)
+	assert.Equal(t, "olia", info.Error)
+}
+
+func TestAdd_Progress(t *testing.T) {
+	m, _ := NewManager(1, 10, time.Minute)
+	defer m.Close()
+	wc, sc := make(chan bool), make(chan bool)
+	info, _ := m.Add(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		progress("olia")
+		sc <- true
+		<-wc
+		return nil, nil
+	})
+	<-sc
+	i, _, ok := m.Get(info.ID)
+	assert.True(t, ok)
+	assert.Equal(t, StatusWorking, i.Status)
+	assert.Equal(t, "olia", i.Stage)
+	close(wc)
+	assert.Equal(t, "", waitFinished(t, m, info.ID).Stage)
+}
+
+func TestAdd_TooBusy(t *testing.T) {
+	m, _ := NewManager(1, 2, time.Minute)
+	defer m.Close()
+	wc := make(chan bool)
+	defer close(wc)
+	w := func(ctx context.Context, progress func(string)) (interface{}, error) {
+		<-wc
+		return nil, nil
+	}
+	_, err := m.Add(w)
+	assert.Nil(t, err)
+	_, err = m.Add(w)
+	assert.Nil(t, err)
+	_, err = m.Add(w)
+	assert.Equal(t, utils.ErrTooBusy, err)
+}
+
+func TestAdd_RemovesOldestFinished(t *testing.T) {
+	m, _ := NewManager(1, 2, time.Minute)
+	defer m.Close()
+	w := func(ctx context.Context, progress func(string)) (interface{}, error) {
+		return nil, nil
+	}
+	i1, _ := m.Add(w)
+	waitFinished(t, m, i1.ID)
+	i2, _ := m.Add(w)
+	waitFinished(t, m, i2.ID)
+	i3, err := m.Add(w)
+	assert.Nil(t, err)
+	waitFinished(t, m, i3.ID)
+	_, _, ok := m.Get(i1.ID)
+	assert.False(t, ok)
+	_, _, ok = m.Get(i2.ID)
+	assert.True(t, ok)
+}
+
+func TestGet_Expired(t *testing.T) {
+	m, _ := NewManager(1, 10, 50*time.Millisecond)
+	defer m.Close()
+	info, _ := m.Add(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		return nil, nil
+	})
+	waitFinished(t, m, info.ID)
+	time.Sleep(60 * time.Millisecond)
+	_, _, ok := m.Get(info.ID)
+	assert.False(t, ok)
+	_, _, ok = m.Get("olia")
+	assert.False(t, ok)
+}
+
+func TestClose_CancelsJob(t *testing.T) {
+	m, _ := NewManager(1, 10, time.Minute)
+	started := make(chan struct{})
+	var jobErr error
+	_, err := m.Add(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		jobErr = ctx.Err()
+		return nil, jobErr
+	})
+	require.Nil(t, err)
+	<-started
+	m.Close()
+	assert.Equal(t, context.Canceled, jobErr)
+}
+
+func waitFinished(t *testing.T, m *Manager, id string) *Info {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		info, _, ok := m.Get(id)
+		require.True(t, ok)
+		if info.Finished != nil {
+			return info
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.Fail(t, "job not finished")
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
 	"github.com/pkg/errors"
 )
@@ -27,41 +28,65 @@ type request struct {
 //Client comunicates with tagger server
 type Client struct {
 	httpclient *http.Client
-	url        string
-	rateLimit  chan struct{}
+	balancer   *backend.Balancer
+	breaker    *backend.Breaker
+	retrier    *backend.Retrier
+	limiter    *backend.Limiter
 	timeOut    time.Duration
 }
 
-//NewClient creates a tagger client
-func NewClient(url string) (*Client, error) {
+//DefaultConfig returns the morph client config with the default values.
+//Morph fails to process more than 10 parallel requests, so it starts with 10
+func DefaultConfig(urls ...string) backend.Config {
+	res := backend.DefaultConfig("morph", urls...)
+	res.LimitInitial, res.LimitMax = 10, 40
+	return res
+}
+
+//NewClient creates a tagger client, requests are balanced between cfg.URLs
+func NewClient(cfg backend.Config) (*Client, error) {
 	res := Client{}
-	if url == "" {
-		return nil, errors.New("No morphology URL")
+	if cfg.Name == "" {
+		cfg.Name = "morph"
+	}
+	var err error
+	if res.balancer, err = backend.NewBalancer(cfg); err != nil {
+		return nil, err
 	}
-	res.url = url
-	res.httpclient = &http.Client{Transport: newTransport()}
-	res.rateLimit = make(chan struct{}, 10)
+	res.breaker = backend.NewBreaker(cfg)
+	res.retrier = backend.NewRetrier(cfg)
+	res.httpclient = &http.Client{Transport: newTransport(cfg.LimitMax)}
+	res.limiter = backend.NewLimiter(cfg)
 	res.timeOut = time.Second * 20
 	return &res, nil
 }
 
-func newTransport() http.RoundTripper {
+//Status returns the state of the endpoints, the circuit breaker and the limiter
+func (t *Client) Status() backend.Status {
+	return backend.NewStatus(t.balancer, t.breaker, t.limiter)
+}
+
+func (t *Client) done(ep *backend.Endpoint, res backend.CallResult) {
+	t.balancer.Done(ep, res)
+	t.breaker.Done(res)
+}
+
+func newTransport(maxConns int) http.RoundTripper {
 	res := http.DefaultTransport.(*http.Transport).Clone()
-	res.MaxIdleConns = 20
-	res.MaxConnsPerHost = 20
-	res.MaxIdleConnsPerHost = 20
+	res.MaxIdleConns = maxConns
+	res.MaxConnsPerHost = maxConns
+	res.MaxIdleConnsPerHost = maxConns
 	return res
 }
 
-//Process invokes ws
-func (t *Client) Process(text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
-	// allow only 10 paraller requests to morph as it fails to process more
-	select {
-	case t.rateLimit <- struct{}{}:
-	case <-time.After(t.timeOut):
-		return nil, utils.ErrTooBusy
+//Process invokes ws. The call is canceled and the limiter slot is released if ctx is done
+func (t *Client) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	permit, err := t.limiter.Acquire(ctx, t.timeOut)
+	if err != nil {
+		return nil, err
 	}
-	defer func() { <-t.rateLimit }()
+	outcome, overloaded, lastFailed := backend.CallIgnored, false, false
+	defer func() { t.limiter.Release(permit, outcome) }()
 
 	goapp.Log.Debug("Process tagger")
 	if text == "" {
@@ -78,23 +103,43 @@ func (t *Client) Process(text string, data *api.SegmenterResult) (*api.TaggerRes
 	if err != nil {
 		return nil, errors.Wrap(err, "can't marshal data")
 	}
-	ctx, cancelF := context.WithTimeout(context.Background(), t.timeOut)
+	ctx, cancelF := context.WithTimeout(ctx, t.timeOut)
 	defer cancelF()
 
 	var result api.TaggerResult
 
 	oneCall := func(ctx context.Context, result *api.TaggerResult) (bool, error) {
-		req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewBuffer(bytesData))
+		lastFailed = false
+		if err := t.breaker.Allow(); err != nil {
+			return false, err
+		}
+		ep := t.balancer.Get()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewBuffer(bytesData))
 		if err != nil {
-			return false, errors.Wrapf(err, "can't prepare request to '%s'", t.url)
+			t.done(ep, backend.CallIgnored)
+			return false, errors.Wrapf(err, "can't prepare request to '%s'", ep.URL)
 		}
 		req.Header.Set("Content-Type", "application/json")
-		req = req.WithContext(ctx)
 		//goapp.Log.Debugf("Input: %s", string(bytesData))
 		resp, err := t.httpclient.Do(req)
 		if err != nil {
-			return true, errors.Wrapf(err, "can't invoke tagger %s", t.url)
+			lastFailed = ctx.Err() == nil
+			overloaded = overloaded || lastFailed
+			if lastFailed {
+				t.done(ep, backend.CallFailed)
+			} else {
+				// a canceled call says nothing about the backend
+				t.done(ep, backend.CallIgnored)
+			}
+			return t.retrier.RetryErrors(), errors.Wrapf(err, "can't invoke tagger %s", ep.URL)
 		}
+		lastFailed = resp.StatusCode >= http.StatusInternalServerError
+		if lastFailed {
+			t.done(ep, backend.CallFailed)
+		} else {
+			t.done(ep, backend.CallOK)
+		}
+		overloaded = overloaded || lastFailed || resp.StatusCode == http.StatusTooManyRequests
 		defer func() {
 			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 10000))
 			_ = resp.Body.Close()
@@ -102,32 +147,23 @@ func (t *Client) Process(text string, data *api.SegmenterResult) (*api.TaggerRes
 
 		err = goapp.ValidateHTTPResp(resp, 100)
 		if err != nil {
-			return utils.IsRetryCode(resp.StatusCode), errors.Wrap(err, "can't invoke tagger")
+			return t.retrier.RetryCode(resp.StatusCode), errors.Wrap(err, "can't invoke tagger")
 		}
 		err = json.NewDecoder(resp.Body).Decode(&result)
 		if err != nil {
-			return true, errors.Wrap(err, "can't decode response")
+			return t.retrier.RetryErrors(), errors.Wrap(err, "can't decode response")
 		}
 		return false, nil
 	}
 
-	for _, st := range utils.ExpBackoffList {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-utils.RandomWait(st):
-		}
-		var retry bool
-		retry, err = oneCall(ctx, &result)
-		if !retry {
-			if err != nil {
-				return nil, err
-			}
-			break
-		}
-		if err != nil {
-			goapp.Log.Warn(err)
-		}
+	err = t.retrier.Do(ctx, func(ctx context.Context) (bool, error) { return oneCall(ctx, &result) })
+	if overloaded {
+		outcome = backend.CallFailed
+	} else if err == nil {
+		outcome = backend.CallOK
+	}
+	if err != nil && lastFailed {
+		return nil, errors.Wrapf(utils.ErrBackendFailed, "%v", err)
 	}
 	if err != nil {
 		return nil, err
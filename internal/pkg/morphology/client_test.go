@@ -1,14 +1,19 @@
 package morphology
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func initServer(t *testing.T, urlStr, resp string, code int) (*Client, *httptest.Server) {
@@ -18,21 +23,23 @@ func initServer(t *testing.T, urlStr, resp string, code int) (*Client, *httptest
 		rw.Write([]byte(resp))
 	}))
 	// Use Client & URL from our local test server
-	api, _ := NewClient(server.URL)
+	api, _ := NewClient(DefaultConfig(server.URL))
 	api.httpclient = server.Client()
 	return api, server
 }
 
 func TestNew(t *testing.T) {
-	c, err := NewClient("url.url")
+	c, err := NewClient(backend.DefaultConfig("morph", "url.url"))
 	assert.Nil(t, err)
 	assert.NotNil(t, c)
 }
 
 func TestNew_Fail(t *testing.T) {
-	c, err := NewClient("")
+	c, err := NewClient(backend.DefaultConfig("morph"))
 	assert.NotNil(t, err)
 	assert.Nil(t, c)
+	_, err = NewClient(backend.DefaultConfig("morph", ""))
+	assert.NotNil(t, err)
 }
 
 func TestProcess(t *testing.T) {
@@ -41,7 +48,7 @@ func TestProcess(t *testing.T) {
 	cl, server := initServer(t, "/", string(rb), 200)
 	defer server.Close()
 
-	r, err := cl.Process("olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
+	r, err := cl.Process(context.Background(), "olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
 
 	assert.Nil(t, err)
 	assert.NotNil(t, r)
@@ -51,16 +58,70 @@ func TestProcess_WrongCode_Fails(t *testing.T) {
 	cl, server := initServer(t, "/", "", 400)
 	defer server.Close()
 
-	r, err := cl.Process("olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
+	r, err := cl.Process(context.Background(), "olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
+	assert.NotNil(t, err)
+	assert.False(t, utils.IsBackendDown(err))
+	assert.Nil(t, r)
+}
+
+func TestProcess_Canceled(t *testing.T) {
+	cl, server := initServer(t, "/", "", 429)
+	defer server.Close()
+	ctx, cancelF := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancelF()
+	}()
+	st := time.Now()
+	r, err := cl.Process(ctx, "olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, r)
+	assert.Less(t, time.Since(st), 2*time.Second)
+	assert.Equal(t, 0, cl.limiter.InFlight())
+}
+
+func TestProcess_CanceledWaiting(t *testing.T) {
+	cl, server := initServer(t, "/", "", 200)
+	defer server.Close()
+	for i := 0; i < cl.limiter.Limit(); i++ {
+		_, err := cl.limiter.Acquire(context.Background(), time.Second)
+		require.Nil(t, err)
+	}
+	ctx, cancelF := context.WithCancel(context.Background())
+	cancelF()
+	r, err := cl.Process(ctx, "olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, r)
+}
+
+func TestProcess_BreakerFailsFast(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	cfg := backend.DefaultConfig("test", server.URL)
+	cfg.BreakerFailures = 1
+	cl, err := NewClient(cfg)
+	require.Nil(t, err)
+
+	_, err = cl.Process(context.Background(), "olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
 	assert.NotNil(t, err)
+	st := time.Now()
+	r, err := cl.Process(context.Background(), "olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
+	assert.Equal(t, utils.ErrBackendUnavailable, err)
 	assert.Nil(t, r)
+	assert.Less(t, time.Since(st), 100*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, "open", cl.Status().Breaker)
 }
 
 func TestProcess_Retry(t *testing.T) {
 	cl, server := initServer(t, "/", "", 429)
 	defer server.Close()
 	cl.timeOut = 500 * time.Millisecond
-	r, err := cl.Process("olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
+	r, err := cl.Process(context.Background(), "olia", &api.SegmenterResult{Seg: [][]int{{1}}, S: [][]int{{1}}})
 	assert.NotNil(t, err)
 	assert.Nil(t, r)
 }
@@ -70,7 +131,7 @@ func TestProcess_NoTex_Fails(t *testing.T) {
 	cl, server := initServer(t, "/", string(rb), 200)
 	defer server.Close()
 
-	r, err := cl.Process("", &api.SegmenterResult{})
+	r, err := cl.Process(context.Background(), "", &api.SegmenterResult{})
 	assert.NotNil(t, err)
 	assert.Nil(t, r)
 }
@@ -80,11 +141,11 @@ func TestProcess_WrongLex_Fails(t *testing.T) {
 	cl, server := initServer(t, "/", string(rb), 200)
 	defer server.Close()
 
-	r, err := cl.Process("olia", nil)
+	r, err := cl.Process(context.Background(), "olia", nil)
 	assert.NotNil(t, err)
 	assert.Nil(t, r)
 
-	r, err = cl.Process("olia", &api.SegmenterResult{})
+	r, err = cl.Process(context.Background(), "olia", &api.SegmenterResult{})
 	assert.NotNil(t, err)
 	assert.Nil(t, r)
 }
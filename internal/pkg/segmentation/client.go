@@ -12,6 +12,7 @@ import (
 
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
 	"github.com/pkg/errors"
 	"mvdan.cc/xurls/v2"
@@ -20,97 +21,131 @@ import (
 //Client comunicates with tagger server
 type Client struct {
 	httpclient *http.Client
-	url        string
-	rateLimit  chan struct{}
+	balancer   *backend.Balancer
+	breaker    *backend.Breaker
+	retrier    *backend.Retrier
+	limiter    *backend.Limiter
 	timeOut    time.Duration
 }
 
-//NewClient creates a tagger client
-func NewClient(url string) (*Client, error) {
+//DefaultConfig returns the lex client config with the default values.
+//Lex fails if several requests go simultaneously, so it starts with one parallel call
+func DefaultConfig(urls ...string) backend.Config {
+	res := backend.DefaultConfig("lex", urls...)
+	res.LimitInitial, res.LimitMax = 1, 4
+	return res
+}
+
+//NewClient creates a lex client, requests are balanced between cfg.URLs
+func NewClient(cfg backend.Config) (*Client, error) {
 	res := Client{}
-	if url == "" {
-		return nil, errors.New("No lex URL")
+	if cfg.Name == "" {
+		cfg.Name = "lex"
+	}
+	var err error
+	if res.balancer, err = backend.NewBalancer(cfg); err != nil {
+		return nil, err
 	}
-	res.url = url
-	res.httpclient = &http.Client{Transport: newTransport()}
-	res.rateLimit = make(chan struct{}, 1)
+	res.breaker = backend.NewBreaker(cfg)
+	res.retrier = backend.NewRetrier(cfg)
+	res.httpclient = &http.Client{Transport: newTransport(cfg.LimitMax)}
+	res.limiter = backend.NewLimiter(cfg)
 	res.timeOut = 20 * time.Second
 
 	return &res, nil
 }
 
-func newTransport() http.RoundTripper {
+//Status returns the state of the endpoints, the circuit breaker and the limiter
+func (t *Client) Status() backend.Status {
+	return backend.NewStatus(t.balancer, t.breaker, t.limiter)
+}
+
+func (t *Client) done(ep *backend.Endpoint, res backend.CallResult) {
+	t.balancer.Done(ep, res)
+	t.breaker.Done(res)
+}
+
+func newTransport(maxConns int) http.RoundTripper {
 	res := http.DefaultTransport.(*http.Transport).Clone()
-	res.MaxIdleConns = 5
-	res.MaxConnsPerHost = 5
-	res.MaxIdleConnsPerHost = 5
+	res.MaxIdleConns = maxConns
+	res.MaxConnsPerHost = maxConns
+	res.MaxIdleConnsPerHost = maxConns
 	return res
 }
 
-//Process invokes ws
-func (t *Client) Process(data string) (*api.SegmenterResult, error) {
+//Process invokes ws. The call is canceled and the limiter slot is released if ctx is done
+func (t *Client) Process(ctx context.Context, data string) (*api.SegmenterResult, error) {
 	if utf8.RuneCountInString(data) == 1 {
 		return &api.SegmenterResult{Seg: [][]int{{0, 1}}, P: [][]int{{0, 1}}, S: [][]int{{0, 1}}}, nil
 	}
 
-	// lex fails if several requests go simultaneously
-	select {
-	case t.rateLimit <- struct{}{}:
-	case <-time.After(t.timeOut):
-		return nil, utils.ErrTooBusy
+	permit, err := t.limiter.Acquire(ctx, t.timeOut)
+	if err != nil {
+		return nil, err
 	}
-	defer func() { <-t.rateLimit }()
+	outcome, overloaded, lastFailed := backend.CallIgnored, false, false
+	defer func() { t.limiter.Release(permit, outcome) }()
 
-	ctx, cancelF := context.WithTimeout(context.Background(), t.timeOut)
+	ctx, cancelF := context.WithTimeout(ctx, t.timeOut)
 	defer cancelF()
 
 	bytesData := []byte(data)
 	var res api.SegmenterResult
 	oneCall := func(ctx context.Context, result *api.SegmenterResult) (bool, error) {
-		req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewBuffer(bytesData))
+		lastFailed = false
+		if err := t.breaker.Allow(); err != nil {
+			return false, err
+		}
+		ep := t.balancer.Get()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewBuffer(bytesData))
 		if err != nil {
-			return false, errors.Wrapf(err, "can't prepare request to '%s'", t.url)
+			t.done(ep, backend.CallIgnored)
+			return false, errors.Wrapf(err, "can't prepare request to '%s'", ep.URL)
 		}
 		req.Header.Set("Content-Type", "application/json")
-		req = req.WithContext(ctx)
 
 		resp, err := t.httpclient.Do(req)
 		if err != nil {
-			return true, errors.Wrapf(err, "can't invoke lex %s", t.url)
+			lastFailed = ctx.Err() == nil
+			overloaded = overloaded || lastFailed
+			if lastFailed {
+				t.done(ep, backend.CallFailed)
+			} else {
+				// a canceled call says nothing about the backend
+				t.done(ep, backend.CallIgnored)
+			}
+			return t.retrier.RetryErrors(), errors.Wrapf(err, "can't invoke lex %s", ep.URL)
+		}
+		lastFailed = resp.StatusCode >= http.StatusInternalServerError
+		if lastFailed {
+			t.done(ep, backend.CallFailed)
+		} else {
+			t.done(ep, backend.CallOK)
 		}
+		overloaded = overloaded || lastFailed || resp.StatusCode == http.StatusTooManyRequests
 		defer func() {
 			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 10000))
 			_ = resp.Body.Close()
 		}()
 		err = goapp.ValidateHTTPResp(resp, 100)
 		if err != nil {
-			return utils.IsRetryCode(resp.StatusCode), errors.Wrap(err, "can't invoke lex")
+			return t.retrier.RetryCode(resp.StatusCode), errors.Wrap(err, "can't invoke lex")
 		}
 		err = json.NewDecoder(resp.Body).Decode(&res)
 		if err != nil {
-			return true, errors.Wrap(err, "can't decode response")
+			return t.retrier.RetryErrors(), errors.Wrap(err, "can't decode response")
 		}
 		return false, nil
 	}
 
-	var err error
-	for _, st := range utils.ExpBackoffList {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-utils.RandomWait(st):
-		}
-		var retry bool
-		retry, err = oneCall(ctx, &res)
-		if !retry {
-			if err != nil {
-				return nil, err
-			}
-			break
-		}
-		if err != nil {
-			goapp.Log.Warn(err)
-		}
+	err = t.retrier.Do(ctx, func(ctx context.Context) (bool, error) { return oneCall(ctx, &res) })
+	if overloaded {
+		outcome = backend.CallFailed
+	} else if err == nil {
+		outcome = backend.CallOK
+	}
+	if err != nil && lastFailed {
+		return nil, errors.Wrapf(utils.ErrBackendFailed, "%v", err)
 	}
 	if err != nil {
 		return nil, err
@@ -120,6 +155,9 @@ func (t *Client) Process(data string) (*api.SegmenterResult, error) {
 	return &res, nil
 }
 
+//RulesVersion is the version of fixSegments rules. Change it on every rules change to invalidate cached results
+const RulesVersion = "1"
+
 var (
 	fixSymbolsMap map[rune]bool
 	urlRegexp     *regexp.Regexp
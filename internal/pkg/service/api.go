@@ -1,9 +1,83 @@
 package service
 
+import "github.com/airenas/lt-pos-tagger/internal/pkg/tagset"
+
+//TextInput is the JSON input of /tag
+type TextInput struct {
+	Text    string   `json:"text"`
+	Options *Options `json:"options,omitempty"`
+}
+
 //ResultWord is service output
 type ResultWord struct {
+	ID     int    `json:"id,omitempty"`
 	Type   string `json:"type"`
 	String string `json:"string,omitempty"`
 	Mi     string `json:"mi,omitempty"`
 	Lemma  string `json:"lemma,omitempty"`
+	Upos   string `json:"upos,omitempty"`
+	Feats  string `json:"feats,omitempty"`
+	//InvalidMi indicates that Mi is not defined in the tagset
+	InvalidMi bool `json:"invalidMi,omitempty"`
+
+	Alternatives []Alternative `json:"alternatives,omitempty"`
+	Span         *Span         `json:"span,omitempty"`
+
+	Features *tagset.Features `json:"features,omitempty"`
+}
+
+//Alternative is one morphological analysis of a word
+type Alternative struct {
+	Lemma    string `json:"lemma"`
+	Mi       string `json:"mi"`
+	Selected bool   `json:"selected,omitempty"`
+}
+
+//Span is a position of the token in the input text.
+//Offset and Length are in runes, ByteOffset and ByteLength - in UTF-8 bytes
+type Span struct {
+	Offset     int `json:"offset"`
+	Length     int `json:"length"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+}
+
+//Document is the service output grouped by paragraphs and sentences
+type Document struct {
+	Paragraphs []Paragraph `json:"paragraphs"`
+	Degraded   *Degraded   `json:"degraded,omitempty"`
+}
+
+//Paragraph is a list of sentences
+type Paragraph struct {
+	ID        int        `json:"id"`
+	Sentences []Sentence `json:"sentences"`
+}
+
+//Sentence is a list of tokens
+type Sentence struct {
+	ID     int          `json:"id"`
+	Tokens []ResultWord `json:"tokens"`
+}
+
+//BatchItem is one document of the batch request
+type BatchItem struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+//BatchResult is the result of one batch document
+type BatchResult struct {
+	ID       string      `json:"id"`
+	Result   interface{} `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Degraded *Degraded   `json:"degraded,omitempty"`
+}
+
+//Degraded lists the reasons why a part of the result was made without a backend
+type Degraded struct {
+	//Segmentation is set if the local segmenter was used instead of lex
+	Segmentation string `json:"segmentation,omitempty"`
+	//Tagging is set if morph failed and only the segmentation is returned
+	Tagging string `json:"tagging,omitempty"`
 }
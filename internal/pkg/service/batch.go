@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultBatchWorkers  = 10
+	defaultBatchMaxItems = 1000
+	defaultBatchMaxLen   = 1000000
+	defaultBatchJoinLen  = 5000
+)
+
+
+func handleBatch(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("Service method: tag/batch")()
+		var items []BatchItem
+		if err := json.NewDecoder(c.Request().Body).Decode(&items); err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(http.StatusBadRequest, "Can't decode input").SetInternal(err)
+		}
+		if len(items) == 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "No input")
+		}
+		if max := getOrDefault(data.BatchMaxItems, defaultBatchMaxItems); len(items) > max {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Too many items, max %d", max))
+		}
+		if max := getOrDefault(data.BatchMaxLen, defaultBatchMaxLen); batchLen(items) > max {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Too long input, max %d symbols", max))
+		}
+		opt, err := parseOptions(c)
+		if err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
+		if err := opt.prepare(); err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
+		ctx, err := data.Priorities.withPriority(c)
+		if err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
+		return c.JSON(http.StatusOK, processBatch(ctx, data, items, opt))
+	}
+}
+
+//processBatch processes documents in groups with a limited count of workers.
+//The short documents of a group are joined as separate paragraphs and analyzed with one lex and morph call.
+//The results are returned in the same order as the items
+func processBatch(ctx context.Context, data *Data, items []BatchItem, opt *Options) []BatchResult {
+	res := make([]BatchResult, len(items))
+	groups := makeBatchGroups(items, res, getOrDefault(data.BatchJoinLen, defaultBatchJoinLen))
+	if len(groups) == 0 {
+		return res
+	}
+	workC := make(chan []int)
+	wg := sync.WaitGroup{}
+	workers := min(getOrDefault(data.BatchWorkers, defaultBatchWorkers), len(groups))
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range workC {
+				processBatchGroup(ctx, data, items, g, opt, res)
+			}
+		}()
+	}
+	for _, g := range groups {
+		workC <- g
+	}
+	close(workC)
+	wg.Wait()
+	return res
+}
+
+//makeBatchGroups returns the indexes of the items joined into one call, the groups are not longer than joinLen runes.
+//Empty items get the error result and are skipped
+func makeBatchGroups(items []BatchItem, res []BatchResult, joinLen int) [][]int {
+	var groups [][]int
+	var group []int
+	groupLen := 0
+	for i := range items {
+		res[i].ID = items[i].ID
+		items[i].Text = strings.TrimSpace(items[i].Text)
+		if items[i].Text == "" {
+			res[i].Error = "No input"
+			continue
+		}
+		l := len([]rune(items[i].Text))
+		if len(group) > 0 && groupLen+utils.TextSeparatorLen+l > joinLen {
+			groups = append(groups, group)
+			group, groupLen = nil, 0
+		}
+		if len(group) > 0 {
+			groupLen += utils.TextSeparatorLen
+		}
+		group = append(group, i)
+		groupLen += l
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+//processBatchGroup analyzes the not cached texts of the group with one call and splits the result back to the items
+func processBatchGroup(ctx context.Context, data *Data, items []BatchItem, group []int, opt *Options, res []BatchResult) {
+	useCache := data.Cache != nil && !opt.NoCache
+	var todo []int
+	for _, i := range group {
+		if useCache {
+			if an, ok := data.Cache.Get(cacheKey(items[i].Text)); ok {
+				setBatchResult(&res[i], data, items[i].Text, an, nil, opt)
+				continue
+			}
+		}
+		todo = append(todo, i)
+	}
+	if len(todo) == 0 {
+		return
+	}
+	if len(todo) == 1 {
+		an, degraded, err := analyze(ctx, data, items[todo[0]].Text, opt, func(string) {})
+		if err != nil {
+			res[todo[0]].Error = errorMessage(err)
+			return
+		}
+		setBatchResult(&res[todo[0]], data, items[todo[0]].Text, an, degraded, opt)
+		return
+	}
+
+	texts := make([]string, len(todo))
+	for k, i := range todo {
+		texts[k] = items[i].Text
+	}
+	text, offsets := utils.JoinTexts(texts)
+	jOpt := *opt
+	jOpt.NoCache = true
+	an, degraded, err := analyze(ctx, data, text, &jOpt, func(string) {})
+	for k, i := range todo {
+		if err != nil {
+			res[i].Error = errorMessage(err)
+			continue
+		}
+		itemAn, cErr := utils.CutAnalysis(an, offsets[k], offsets[k]+len([]rune(texts[k])))
+		if cErr != nil {
+			goapp.Log.Error(cErr)
+			res[i].Error = "Can't map"
+			continue
+		}
+		if useCache && degraded == nil {
+			data.Cache.Add(cacheKey(texts[k]), itemAn)
+		}
+		setBatchResult(&res[i], data, texts[k], itemAn, degraded, opt)
+	}
+}
+
+func setBatchResult(res *BatchResult, data *Data, text string, an *api.Analysis, degraded *Degraded, opt *Options) {
+	pr, err := makeResult(data, text, an, degraded, opt)
+	if err != nil {
+		res.Error = errorMessage(err)
+		return
+	}
+	res.Result, res.Degraded = formatResult(pr, opt), pr.degraded
+}
+
+func batchLen(items []BatchItem) int {
+	res := 0
+	for _, it := range items {
+		res += utf8.RuneCountInString(it.Text)
+	}
+	return res
+}
+
+func errorMessage(err error) string {
+	if he, ok := err.(*echo.HTTPError); ok {
+		return fmt.Sprintf("%v", he.Message)
+	}
+	return err.Error()
+}
+
+func getOrDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+)
+
+const mimeCoNLLU = "text/x-conllu"
+
+var conlluTextReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+//toCoNLLU writes the document in CoNLL-U format, see https://universaldependencies.org/format.html
+func toCoNLLU(doc *Document) string {
+	res := strings.Builder{}
+	spaceAfter := make(map[int]bool)
+	var prev *ResultWord
+	for _, p := range doc.Paragraphs {
+		for _, s := range p.Sentences {
+			for i := range s.Tokens {
+				w := &s.Tokens[i]
+				if prev != nil && prev.Type != "SPACE" {
+					spaceAfter[prev.ID] = w.Type == "SPACE"
+				}
+				prev = w
+			}
+		}
+	}
+	for _, p := range doc.Paragraphs {
+		newPar := true
+		for _, s := range p.Sentences {
+			words := conlluWords(s.Tokens)
+			if len(words) == 0 {
+				continue
+			}
+			if newPar {
+				res.WriteString("# newpar id = " + strconv.Itoa(p.ID) + "\n")
+				newPar = false
+			}
+			res.WriteString("# sent_id = " + strconv.Itoa(s.ID) + "\n")
+			res.WriteString("# text = " + conlluText(s.Tokens) + "\n")
+			for i, w := range words {
+				sa, ok := spaceAfter[w.ID]
+				misc := "_"
+				if ok && !sa {
+					misc = "SpaceAfter=No"
+				}
+				res.WriteString(strings.Join([]string{strconv.Itoa(i + 1), w.String, conlluValue(w.Lemma), conlluValue(w.Upos),
+					conlluValue(w.Mi), conlluValue(w.Feats), "_", "_", "_", misc}, "\t"))
+				res.WriteString("\n")
+			}
+			res.WriteString("\n")
+		}
+	}
+	return res.String()
+}
+
+func conlluWords(tokens []ResultWord) []ResultWord {
+	res := make([]ResultWord, 0, len(tokens))
+	for _, w := range tokens {
+		if w.Type != "SPACE" {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+func conlluText(tokens []ResultWord) string {
+	from, to := 0, len(tokens)
+	for from < to && tokens[from].Type == "SPACE" {
+		from++
+	}
+	for to > from && tokens[to-1].Type == "SPACE" {
+		to--
+	}
+	res := strings.Builder{}
+	for _, w := range tokens[from:to] {
+		res.WriteString(w.String)
+	}
+	return conlluTextReplacer.Replace(res.String())
+}
+
+func conlluValue(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return s
+}
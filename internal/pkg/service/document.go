@@ -0,0 +1,95 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+//headerDegraded is set if a part of the result was made without a backend
+const headerDegraded = "X-Tagger-Degraded"
+
+//result is the processed text
+type result struct {
+	words    []ResultWord
+	degraded *Degraded
+}
+
+func writeResult(c echo.Context, res *result, opt *Options) error {
+	if res.degraded != nil {
+		c.Response().Header().Set(headerDegraded, res.degraded.header())
+	}
+	if opt.Format == formatCoNLLU {
+		return c.Blob(http.StatusOK, mimeCoNLLU+"; charset=UTF-8", []byte(toCoNLLU(makeDocument(res.words))))
+	}
+	return c.JSON(http.StatusOK, formatResult(res, opt))
+}
+
+//formatResult returns the result in the requested format, CoNLL-U is returned as a string
+func formatResult(res *result, opt *Options) interface{} {
+	switch opt.Format {
+	case formatDocument:
+		d := makeDocument(res.words)
+		d.Degraded = res.degraded
+		return d
+	case formatCoNLLU:
+		return toCoNLLU(makeDocument(res.words))
+	}
+	return res.words
+}
+
+//header returns the degraded parts with the reasons, e.g. segmentation=lex is unavailable
+func (d *Degraded) header() string {
+	var res []string
+	if d.Segmentation != "" {
+		res = append(res, "segmentation="+d.Segmentation)
+	}
+	if d.Tagging != "" {
+		res = append(res, "tagging="+d.Tagging)
+	}
+	return strings.Join(res, "; ")
+}
+
+//makeDocument groups tokens into sentences and paragraphs by the SENTENCE_END, PARAGRAPH_END markers.
+//Paragraphs, sentences and tokens are numbered sequentially through the whole document starting from 1
+func makeDocument(words []ResultWord) *Document {
+	res := &Document{Paragraphs: make([]Paragraph, 0)}
+	var par *Paragraph
+	var sent *Sentence
+	tID, sID := 0, 0
+	closeSentence := func() {
+		if sent != nil {
+			par.Sentences = append(par.Sentences, *sent)
+			sent = nil
+		}
+	}
+	closeParagraph := func() {
+		closeSentence()
+		if par != nil {
+			res.Paragraphs = append(res.Paragraphs, *par)
+			par = nil
+		}
+	}
+	for _, w := range words {
+		switch w.Type {
+		case "SENTENCE_END":
+			closeSentence()
+		case "PARAGRAPH_END":
+			closeParagraph()
+		default:
+			if par == nil {
+				par = &Paragraph{ID: len(res.Paragraphs) + 1, Sentences: make([]Sentence, 0)}
+			}
+			if sent == nil {
+				sID++
+				sent = &Sentence{ID: sID, Tokens: make([]ResultWord, 0)}
+			}
+			tID++
+			w.ID = tID
+			sent.Tokens = append(sent.Tokens, w)
+		}
+	}
+	closeParagraph()
+	return res
+}
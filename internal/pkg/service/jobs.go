@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/airenas/go-app/pkg/goapp"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/jobs"
+	"github.com/labstack/echo/v4"
+	"github.com/pkg/errors"
+)
+
+type jobResult struct {
+	res *result
+	opt *Options
+}
+
+func handleJobAdd(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		defer goapp.Estimate("Service method: jobs add")()
+		opt, err := parseOptions(c)
+		if err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
+		tb := &textBinder{}
+		var text string
+		if err := tb.Bind(c, &text, opt); err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
+		if err := opt.prepare(); err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
+		pr, err := data.Priorities.get(c)
+		if err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
+		info, err := data.Jobs.Add(func(ctx context.Context, progress func(string)) (interface{}, error) {
+			res, err := process(backend.WithPriority(ctx, pr), data, text, opt, progress)
+			if err != nil {
+				return nil, errors.New(errorMessage(err))
+			}
+			return &jobResult{res: res, opt: opt}, nil
+		})
+		if err != nil {
+			goapp.Log.Error(err)
+			return echo.NewHTTPError(mapHTTPError(err), "Can't add job")
+		}
+		c.Response().Header().Set(echo.HeaderLocation, "/jobs/"+info.ID)
+		return c.JSON(http.StatusAccepted, info)
+	}
+}
+
+func handleJobStatus(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		info, _, ok := data.Jobs.Get(c.Param("id"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "No job")
+		}
+		return c.JSON(http.StatusOK, info)
+	}
+}
+
+func handleJobResult(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		info, res, ok := data.Jobs.Get(c.Param("id"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "No job")
+		}
+		switch info.Status {
+		case jobs.StatusDone:
+		case jobs.StatusFailed:
+			return echo.NewHTTPError(http.StatusConflict, "Job failed: "+info.Error)
+		default:
+			return echo.NewHTTPError(http.StatusConflict, "Job is not finished")
+		}
+		jr, ok := res.(*jobResult)
+		if !ok {
+			goapp.Log.Errorf("wrong job result type %T", res)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Can't get result")
+		}
+		return writeResult(c, jr.res, jr.opt)
+	}
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var totalInvalidMsd = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "invalid_msd_total",
+	Help:      "The total number of MSD tags not defined in the tagset",
+})
+
+var totalSegmentationFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "segmentation_fallback_total",
+	Help:      "The total number of texts segmented by the fallback segmenter",
+})
+
+var totalTaggingDegraded = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "tag",
+	Name:      "tagging_degraded_total",
+	Help:      "The total number of texts returned without morph",
+})
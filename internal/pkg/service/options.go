@@ -0,0 +1,133 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	formatJSON     = "json"
+	formatDocument = "document"
+	formatCoNLLU   = "conllu"
+
+	mimeDocument = "application/vnd.tagger.document+json"
+
+	lemmaCaseLower = "lower"
+	lemmaCaseUpper = "upper"
+)
+
+//Options are request processing options
+type Options struct {
+	//Format is the response format: json - a flat list of tokens, document - tokens grouped by paragraphs and sentences,
+	//conllu - CoNLL-U text
+	Format string `json:"format,omitempty"`
+	//Alternatives adds all morphological analyses to WORD and NUMBER tokens
+	Alternatives bool `json:"alternatives,omitempty"`
+	//Offsets adds the token position in the input text
+	Offsets bool `json:"offsets,omitempty"`
+	//Paragraphs adds PARAGRAPH_END tokens
+	Paragraphs bool `json:"paragraphs,omitempty"`
+	//UD adds Universal Dependencies UPOS and FEATS values
+	UD bool `json:"ud,omitempty"`
+	//Features adds decoded MSD values
+	Features bool `json:"features,omitempty"`
+	//SkipSpaces drops SPACE tokens from the result
+	SkipSpaces bool `json:"skipSpaces,omitempty"`
+	//LemmaCase changes the case of lemmas: lower, upper. Lemmas are returned as provided by morph if empty
+	LemmaCase string `json:"lemmaCase,omitempty"`
+	//NoCache skips the result cache
+	NoCache bool `json:"noCache,omitempty"`
+	//AllowDegraded returns the segmentation only tokens if morph fails
+	AllowDegraded bool `json:"allowDegraded,omitempty"`
+}
+
+//parseOptions reads options from the URL query and the Accept header
+func parseOptions(c echo.Context) (*Options, error) {
+	res := &Options{}
+	var err error
+	if res.Alternatives, err = queryBool(c, "alternatives"); err != nil {
+		return nil, err
+	}
+	if res.Offsets, err = queryBool(c, "offsets"); err != nil {
+		return nil, err
+	}
+	if res.Paragraphs, err = queryBool(c, "paragraphs"); err != nil {
+		return nil, err
+	}
+	if res.UD, err = queryBool(c, "ud"); err != nil {
+		return nil, err
+	}
+	if res.Features, err = queryBool(c, "features"); err != nil {
+		return nil, err
+	}
+	if res.SkipSpaces, err = queryBool(c, "skipSpaces"); err != nil {
+		return nil, err
+	}
+	if res.NoCache, err = queryBool(c, "noCache"); err != nil {
+		return nil, err
+	}
+	if res.AllowDegraded, err = queryBool(c, "allowDegraded"); err != nil {
+		return nil, err
+	}
+	res.LemmaCase = c.QueryParam("lemmaCase")
+	res.Format = getFormat(c)
+	return res, nil
+}
+
+//prepare validates options and sets the ones required by the format
+func (o *Options) prepare() error {
+	if o.Format == "" {
+		o.Format = formatJSON
+	}
+	if o.Format != formatJSON && o.Format != formatDocument && o.Format != formatCoNLLU {
+		return echo.NewHTTPError(http.StatusBadRequest, "Wrong format '"+o.Format+"'")
+	}
+	if o.LemmaCase != "" && o.LemmaCase != lemmaCaseLower && o.LemmaCase != lemmaCaseUpper {
+		return echo.NewHTTPError(http.StatusBadRequest, "Wrong lemmaCase '"+o.LemmaCase+"'")
+	}
+	// document and conllu are grouped by the paragraph markers
+	o.Paragraphs = o.Paragraphs || o.Format != formatJSON
+	o.UD = o.UD || o.Format == formatCoNLLU
+	// conllu needs spaces for SpaceAfter
+	o.SkipSpaces = o.SkipSpaces && o.Format != formatCoNLLU
+	return nil
+}
+
+func queryBool(c echo.Context, name string) (bool, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return false, nil
+	}
+	res, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusBadRequest, "Wrong param '"+name+"' value").SetInternal(err)
+	}
+	return res, nil
+}
+
+func getFormat(c echo.Context) string {
+	res := c.QueryParam("format")
+	if res == "" {
+		accept := c.Request().Header.Get(echo.HeaderAccept)
+		if strings.Contains(accept, mimeDocument) {
+			return formatDocument
+		}
+		if strings.Contains(accept, mimeCoNLLU) {
+			return formatCoNLLU
+		}
+	}
+	return res
+}
+
+func changeCase(s, lemmaCase string) string {
+	switch lemmaCase {
+	case lemmaCaseLower:
+		return strings.ToLower(s)
+	case lemmaCaseUpper:
+		return strings.ToUpper(s)
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerPriority = "X-Priority"
+	headerAPIKey   = "X-API-Key"
+)
+
+//Priorities selects the priority of the request backend calls
+type Priorities struct {
+	//Keys maps the X-API-Key header values to priorities
+	Keys map[string]backend.Priority
+	//TrustHeader allows to raise the priority with the X-Priority header. The header can always lower it
+	TrustHeader bool
+	//Default is the priority of the requests without a known key or the header
+	Default backend.Priority
+}
+
+//withPriority returns the request context with the priority. nil p trusts the X-Priority header
+func (p *Priorities) withPriority(c echo.Context) (context.Context, error) {
+	pr, err := p.get(c)
+	if err != nil {
+		return nil, err
+	}
+	return backend.WithPriority(c.Request().Context(), pr), nil
+}
+
+func (p *Priorities) get(c echo.Context) (backend.Priority, error) {
+	if p == nil {
+		p = &Priorities{TrustHeader: true}
+	}
+	res := p.Default
+	if pr, ok := p.Keys[c.Request().Header.Get(headerAPIKey)]; ok {
+		res = pr
+	}
+	h := c.Request().Header.Get(headerPriority)
+	if h == "" {
+		return res, nil
+	}
+	pr, err := backend.ParsePriority(h)
+	if err != nil {
+		return res, echo.NewHTTPError(http.StatusBadRequest, "Wrong header '"+headerPriority+"' value").SetInternal(err)
+	}
+	if pr < res || p.TrustHeader {
+		return pr, nil
+	}
+	return res, nil
+}
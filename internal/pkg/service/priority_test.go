@@ -0,0 +1,79 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorities_Get(t *testing.T) {
+	keys := map[string]backend.Priority{"bulk": backend.PriorityLow, "editor": backend.PriorityHigh}
+	tests := []struct {
+		name   string
+		p      *Priorities
+		key    string
+		header string
+		want   backend.Priority
+	}{
+		{name: "nil", p: nil, want: backend.PriorityNormal},
+		{name: "nil header", p: nil, header: "high", want: backend.PriorityHigh},
+		{name: "default", p: &Priorities{Default: backend.PriorityLow}, want: backend.PriorityLow},
+		{name: "key", p: &Priorities{Keys: keys}, key: "editor", want: backend.PriorityHigh},
+		{name: "unknown key", p: &Priorities{Keys: keys}, key: "olia", want: backend.PriorityNormal},
+		{name: "not trusted header", p: &Priorities{Keys: keys}, header: "high", want: backend.PriorityNormal},
+		{name: "lowers", p: &Priorities{Keys: keys}, key: "editor", header: "low", want: backend.PriorityLow},
+		{name: "trusted header", p: &Priorities{Keys: keys, TrustHeader: true}, key: "bulk", header: "high",
+			want: backend.PriorityHigh},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initTest(t)
+			req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+			if tt.key != "" {
+				req.Header.Set(headerAPIKey, tt.key)
+			}
+			if tt.header != "" {
+				req.Header.Set(headerPriority, tt.header)
+			}
+			got, err := tt.p.get(tEcho.NewContext(req, tResp))
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestProvides_Priority(t *testing.T) {
+	initTest(t)
+	tData.Priorities = &Priorities{Keys: map[string]backend.Priority{"editor": backend.PriorityHigh}}
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+	req.Header.Set(headerAPIKey, "editor")
+
+	tEcho.ServeHTTP(tResp, req)
+	require.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, backend.PriorityHigh, backend.PriorityFrom(tData.Segmenter.(*testLex).ctx))
+	assert.Equal(t, backend.PriorityHigh, backend.PriorityFrom(tData.Tagger.(*testTagger).ctx))
+}
+
+func TestFails_WrongPriority(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+	req.Header.Set(headerPriority, "urgent")
+
+	tEcho.ServeHTTP(tResp, req)
+	assert.Equal(t, http.StatusBadRequest, tResp.Code)
+}
+
+func TestBatch_Priority(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag/batch", strings.NewReader(`[{"id":"1","text":"mama o"}]`))
+	req.Header.Set(headerPriority, "low")
+
+	tEcho.ServeHTTP(tResp, req)
+	require.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, backend.PriorityLow, backend.PriorityFrom(tData.Segmenter.(*testLex).ctx))
+}
@@ -1,15 +1,25 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/airenas/go-app/pkg/goapp"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/jobs"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/tagset"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
 	"github.com/facebookgo/grace/gracehttp"
 	"github.com/labstack/echo-contrib/prometheus"
@@ -21,19 +31,56 @@ import (
 type (
 	// Tagger returns word forms
 	Tagger interface {
-		Process(string, *api.SegmenterResult) (*api.TaggerResult, error)
+		Process(context.Context, string, *api.SegmenterResult) (*api.TaggerResult, error)
 	}
 
 	//Segmenter segments text
 	Segmenter interface {
-		Process(text string) (*api.SegmenterResult, error)
+		Process(ctx context.Context, text string) (*api.SegmenterResult, error)
+	}
+
+	//Cache keeps the segmentation and tagging results of texts
+	Cache interface {
+		Get(key string) (*api.Analysis, bool)
+		Add(key string, value *api.Analysis)
+	}
+
+	//Backend reports the state of a lex or morph client
+	Backend interface {
+		Status() backend.Status
+	}
+
+	//JobManager runs async jobs
+	JobManager interface {
+		Add(w jobs.Work) (*jobs.Info, error)
+		Get(id string) (*jobs.Info, interface{}, bool)
 	}
 
 	//Data is service operation data
 	Data struct {
 		Tagger    Tagger
 		Segmenter Segmenter
-		Port      int
+		//FallbackSegmenter is used if Segmenter fails, the result is marked as degraded. No fallback if nil
+		FallbackSegmenter Segmenter
+		//Tagset is the MSD definition, the embedded one is used if nil
+		Tagset *tagset.Tagset
+		Port   int
+		//BatchWorkers is the count of document groups processed in parallel by /tag/batch
+		BatchWorkers int
+		//BatchMaxItems is the max count of documents in one /tag/batch request
+		BatchMaxItems int
+		//BatchMaxLen is the max total length in runes of the documents in one /tag/batch request
+		BatchMaxLen int
+		//BatchJoinLen is the max length in runes of the /tag/batch documents joined into one lex and morph call
+		BatchJoinLen int
+		//Jobs runs async /jobs requests, the /jobs endpoints are disabled if nil
+		Jobs JobManager
+		//Cache keeps the results of the processed texts, no caching if nil
+		Cache Cache
+		//Backends are reported by /status
+		Backends []Backend
+		//Priorities selects the priority of the backend calls by the request headers, the X-Priority header is trusted if nil
+		Priorities *Priorities
 	}
 )
 
@@ -64,7 +111,14 @@ func initRoutes(data *Data) *echo.Echo {
 	p.Use(e)
 
 	e.POST("/tag", handleText(data))
+	e.POST("/tag/batch", handleBatch(data))
+	if data.Jobs != nil {
+		e.POST("/jobs", handleJobAdd(data))
+		e.GET("/jobs/:id", handleJobStatus(data))
+		e.GET("/jobs/:id/result", handleJobResult(data))
+	}
 	e.GET("/live", live(data))
+	e.GET("/status", status(data))
 
 	goapp.Log.Info("Routes:")
 	for _, r := range e.Routes() {
@@ -75,11 +129,22 @@ func initRoutes(data *Data) *echo.Echo {
 
 type textBinder struct{}
 
-func (cb *textBinder) Bind(c echo.Context, s *string) error {
+//Bind reads the text from the body. The body can be a plain text or a JSON object {"text": "...", "options": {...}}
+//if the content type is application/json. JSON options override the ones in opt
+func (cb *textBinder) Bind(c echo.Context, s *string, opt *Options) error {
 	bodyBytes, err := ioutil.ReadAll(c.Request().Body)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Can't get data").SetInternal(err)
 	}
+	if isJSONInput(c, bodyBytes) {
+		in := TextInput{Options: opt}
+		d := json.NewDecoder(bytes.NewReader(bodyBytes))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&in); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Wrong input: "+err.Error()).SetInternal(err)
+		}
+		bodyBytes = []byte(in.Text)
+	}
 	*s = strings.TrimSpace(string(bodyBytes))
 	if *s == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, "No input")
@@ -87,44 +152,141 @@ func (cb *textBinder) Bind(c echo.Context, s *string) error {
 	return nil
 }
 
+// a plain text may be sent with the JSON content type by older clients, a body starting with { must be a valid JSON object
+func isJSONInput(c echo.Context, data []byte) bool {
+	return strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) &&
+		strings.HasPrefix(strings.TrimSpace(string(data)), "{")
+}
+
 func handleText(data *Data) func(echo.Context) error {
 	return func(c echo.Context) error {
 		defer goapp.Estimate("Service method: tag")()
+		opt, err := parseOptions(c)
+		if err != nil {
+			goapp.Log.Error(err)
+			return err
+		}
 		tb := &textBinder{}
 		var text string
-		if err := tb.Bind(c, &text); err != nil {
+		if err := tb.Bind(c, &text, opt); err != nil {
 			goapp.Log.Error(err)
 			return err
 		}
-
-		sgm, err := data.Segmenter.Process(text)
-		if err != nil {
+		if err := opt.prepare(); err != nil {
 			goapp.Log.Error(err)
-			return echo.NewHTTPError(mapHTTPError(err), "Can't segment")
+			return err
 		}
-
-		tgr, err := data.Tagger.Process(text, sgm)
+		ctx, err := data.Priorities.withPriority(c)
 		if err != nil {
 			goapp.Log.Error(err)
-			return echo.NewHTTPError(mapHTTPError(err), "Can't tag")
+			return err
 		}
-		goapp.Log.Debugf("Tagger: %v", tgr)
 
-		res, err := MapRes(text, tgr, sgm)
+		res, err := process(ctx, data, text, opt, nil)
 		if err != nil {
-			goapp.Log.Error(err)
-			return echo.NewHTTPError(http.StatusInternalServerError, "Can't map")
+			return err
 		}
-		goapp.Log.Debugf("Res: %v", res)
+		return writeResult(c, res, opt)
+	}
+}
 
-		return c.JSON(http.StatusOK, res)
+//process segments, tags the text and maps the result, returns echo.HTTPError on failure.
+//progress is called before every stage if not nil. The backend calls are canceled if ctx is done
+func process(ctx context.Context, data *Data, text string, opt *Options, progress func(stage string)) (*result, error) {
+	if progress == nil {
+		progress = func(string) {}
 	}
+	an, degraded, err := analyze(ctx, data, text, opt, progress)
+	if err != nil {
+		return nil, err
+	}
+	progress("mapping")
+	return makeResult(data, text, an, degraded, opt)
+}
+
+//makeResult maps the analysis to the result tokens, returns echo.HTTPError on failure
+func makeResult(data *Data, text string, an *api.Analysis, degraded *Degraded, opt *Options) (*result, error) {
+	res, err := mapRes(text, an.Tags, an.Segments, data.getTagset(), opt)
+	if err != nil {
+		goapp.Log.Error(err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Can't map")
+	}
+	goapp.Log.Debugf("Res: %v", res)
+	return &result{words: res, degraded: degraded}, nil
+}
+
+//analyze returns the segmentation and tagging result from the cache or from the backends.
+//Degraded results are not cached
+func analyze(ctx context.Context, data *Data, text string, opt *Options,
+	progress func(stage string)) (*api.Analysis, *Degraded, error) {
+	useCache := data.Cache != nil && !opt.NoCache
+	key := ""
+	if useCache {
+		key = cacheKey(text)
+		if res, ok := data.Cache.Get(key); ok {
+			goapp.Log.Debug("Found in cache")
+			return res, nil, nil
+		}
+	}
+	progress("segmentation")
+	var degraded *Degraded
+	sgm, err := data.Segmenter.Process(ctx, text)
+	// no fallback for 429 and 4xx, the fallback is only for the unavailable lex
+	if err != nil && data.FallbackSegmenter != nil && ctx.Err() == nil && utils.IsBackendDown(err) {
+		goapp.Log.Warn(errors.Wrap(err, "lex failed, using fallback segmenter"))
+		totalSegmentationFallbacks.Inc()
+		degraded = &Degraded{Segmentation: "lex is unavailable"}
+		sgm, err = data.FallbackSegmenter.Process(ctx, text)
+	}
+	if err != nil {
+		goapp.Log.Error(err)
+		return nil, nil, echo.NewHTTPError(mapHTTPError(err), "Can't segment")
+	}
+
+	progress("tagging")
+	tgr, err := data.Tagger.Process(ctx, text, sgm)
+	if err != nil && opt.AllowDegraded && ctx.Err() == nil {
+		goapp.Log.Warn(errors.Wrap(err, "morph failed, returning segmentation only"))
+		totalTaggingDegraded.Inc()
+		if degraded == nil {
+			degraded = &Degraded{}
+		}
+		degraded.Tagging = "morph is unavailable"
+		return &api.Analysis{Segments: sgm}, degraded, nil
+	}
+	if err != nil {
+		goapp.Log.Error(err)
+		return nil, nil, echo.NewHTTPError(mapHTTPError(err), "Can't tag")
+	}
+	goapp.Log.Debugf("Tagger: %v", tgr)
+
+	res := &api.Analysis{Segments: sgm, Tags: tgr}
+	if useCache && degraded == nil {
+		data.Cache.Add(key, res)
+	}
+	return res, degraded, nil
+}
+
+//cacheKey is a hash of the text, the text is already trimmed by the binder
+func cacheKey(text string) string {
+	h := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(h[:])
+}
+
+func (d *Data) getTagset() *tagset.Tagset {
+	if d.Tagset == nil {
+		return tagset.Default()
+	}
+	return d.Tagset
 }
 
 func mapHTTPError(err error) int {
 	if err == utils.ErrTooBusy {
 		return http.StatusTooManyRequests
 	}
+	if err == utils.ErrBackendUnavailable {
+		return http.StatusServiceUnavailable
+	}
 	return http.StatusInternalServerError
 }
 
@@ -134,13 +296,46 @@ func live(data *Data) func(echo.Context) error {
 	}
 }
 
+//status returns the state of the backend endpoints and circuit breakers
+func status(data *Data) func(echo.Context) error {
+	return func(c echo.Context) error {
+		res := struct {
+			Backends []backend.Status `json:"backends"`
+		}{Backends: make([]backend.Status, 0, len(data.Backends))}
+		for _, b := range data.Backends {
+			res.Backends = append(res.Backends, b.Status())
+		}
+		return c.JSON(http.StatusOK, res)
+	}
+}
+
 //MapRes map function
 func MapRes(text string, tgr *api.TaggerResult, sgm *api.SegmenterResult) ([]ResultWord, error) {
+	return mapRes(text, tgr, sgm, tagset.Default(), &Options{})
+}
+
+//mapRes makes the result tokens. The tokens are classified heuristically without lemma and mi if tgr is nil
+func mapRes(text string, tgr *api.TaggerResult, sgm *api.SegmenterResult, ts *tagset.Tagset, opt *Options) ([]ResultWord, error) {
 	res := make([]ResultWord, 0)
 	si := 0
 	ep := 0
 	rns := []rune(text)
-	sent := getSentence(sgm.S, si)
+	var bp []int
+	if opt.Offsets {
+		bp = bytePositions(rns)
+	}
+	add := func(w ResultWord, from, to int) {
+		if opt.SkipSpaces && w.Type == "SPACE" {
+			return
+		}
+		if opt.Offsets {
+			w.Span = &Span{Offset: from, Length: to - from, ByteOffset: bp[from], ByteLength: bp[to] - bp[from]}
+		}
+		res = append(res, w)
+	}
+	sent := getSpan(sgm.S, si)
+	pi := 0
+	par := getSpan(sgm.P, pi)
 	for i, s := range sgm.Seg {
 		if len(s) < 2 {
 			return nil, errors.Errorf("Wrong seg (< 2) %v", s)
@@ -155,38 +350,107 @@ func MapRes(text string, tgr *api.TaggerResult, sgm *api.SegmenterResult) ([]Res
 			return nil, errors.Errorf("No sentence for %v", s)
 		}
 		t := string(rns[s[0] : s[0]+s[1]])
-		if len(tgr.Msd) <= i {
-			return nil, errors.Errorf("No msd at %d. %s", i, tryTakeText(rns, s[0]))
-		}
-		if len(tgr.Msd[i]) < 1 {
-			return nil, errors.Errorf("Wrong msd at (len < 1) %d. %s", i, tryTakeText(rns, s[0]))
-		}
-		if len(tgr.Msd[i][0]) < 2 {
-			return nil, errors.Errorf("Wrong msd at (len[0] < 2) %d. %s", i, tryTakeText(rns, s[0]))
+		var w ResultWord
+		if tgr == nil {
+			w = guessWord(t)
+		} else {
+			var err error
+			if w, err = taggedWord(t, tgr, i, ts, opt); err != nil {
+				return nil, errors.Errorf("%v. %s", err, tryTakeText(rns, s[0]))
+			}
 		}
-		mi := tgr.Msd[i][0][1]
 		if ep < s[0] {
-			res = append(res, space(string(rns[ep:s[0]])))
-		}
-
-		if isNum(t, mi) {
-			res = append(res, num(t, mi))
-		} else if isSep(mi) {
-			res = append(res, sep(t, mi))
-		} else {
-			res = append(res, word(t, tgr.Msd[i][0][0], mi))
+			add(space(string(rns[ep:s[0]])), ep, s[0])
 		}
 		ep = s[0] + s[1]
+		add(w, s[0], ep)
 		if ep >= (sent[0] + sent[1]) {
-			res = append(res, sentenceEnd())
+			add(sentenceEnd(), ep, ep)
 			si++
-			sent = getSentence(sgm.S, si)
+			sent = getSpan(sgm.S, si)
+		}
+		for opt.Paragraphs && par != nil && ep >= (par[0]+par[1]) {
+			add(paragraphEnd(), ep, ep)
+			pi++
+			par = getSpan(sgm.P, pi)
+		}
+	}
+	return res, nil
+}
+
+//taggedWord makes the token from the i-th msd
+func taggedWord(t string, tgr *api.TaggerResult, i int, ts *tagset.Tagset, opt *Options) (ResultWord, error) {
+	if len(tgr.Msd) <= i {
+		return ResultWord{}, errors.Errorf("No msd at %d", i)
+	}
+	if len(tgr.Msd[i]) < 1 {
+		return ResultWord{}, errors.Errorf("Wrong msd at (len < 1) %d", i)
+	}
+	if len(tgr.Msd[i][0]) < 2 {
+		return ResultWord{}, errors.Errorf("Wrong msd at (len[0] < 2) %d", i)
+	}
+	mi := tgr.Msd[i][0][1]
+	var w ResultWord
+	if isNum(ts, t, mi) {
+		w = num(ts, t, mi)
+	} else if isSep(ts, mi) {
+		w = sep(t, mi)
+	} else {
+		w = word(t, changeCase(tgr.Msd[i][0][0], opt.LemmaCase), mi)
+	}
+	if !ts.Validate(w.Mi) {
+		w.InvalidMi = true
+		totalInvalidMsd.Inc()
+	}
+	if opt.UD {
+		w.Upos, w.Feats = ts.ToUD(w.Mi)
+	}
+	if opt.Features {
+		w.Features = ts.Decode(w.Mi)
+	}
+	if opt.Alternatives && w.Type != "SEPARATOR" {
+		var err error
+		if w.Alternatives, err = alternatives(tgr.Msd[i], opt.LemmaCase); err != nil {
+			return ResultWord{}, errors.Wrapf(err, "wrong msd at %d", i)
+		}
+		// the selected alternative shows the same mi as the token, e.g. the mapped number mi
+		w.Alternatives[0].Mi = w.Mi
+	}
+	return w, nil
+}
+
+//guessWord classifies the token without morph: NUMBER, SEPARATOR or WORD
+func guessWord(t string) ResultWord {
+	if utils.IsNumber(t) {
+		return ResultWord{Type: "NUMBER", String: t}
+	}
+	if strings.IndexFunc(t, func(r rune) bool { return !unicode.IsPunct(r) && !unicode.IsSymbol(r) }) < 0 {
+		return ResultWord{Type: "SEPARATOR", String: t}
+	}
+	return ResultWord{Type: "WORD", String: t}
+}
+
+// bytePositions returns UTF-8 byte offsets of each rune, the last item is the total byte length
+func bytePositions(rns []rune) []int {
+	res := make([]int, len(rns)+1)
+	for i, r := range rns {
+		res[i+1] = res[i] + utf8.RuneLen(r)
+	}
+	return res
+}
+
+func alternatives(msd [][]string, lemmaCase string) ([]Alternative, error) {
+	res := make([]Alternative, 0, len(msd))
+	for i, m := range msd {
+		if len(m) < 2 {
+			return nil, errors.Errorf("wrong alternative (len[%d] < 2)", i)
 		}
+		res = append(res, Alternative{Lemma: changeCase(m[0], lemmaCase), Mi: m[1], Selected: i == 0})
 	}
 	return res, nil
 }
 
-func getSentence(s [][]int, i int) []int {
+func getSpan(s [][]int, i int) []int {
 	if len(s) <= i {
 		return nil
 	}
@@ -230,23 +494,23 @@ func sentenceEnd() ResultWord {
 	return ResultWord{Type: "SENTENCE_END"}
 }
 
-func num(s string, mi string) ResultWord {
-	tmi := mi
-	if mi == "Th" || mi == "X-" {
-		tmi = "M----d-" // negative number workaround
-	}
-	return ResultWord{Type: "NUMBER", String: s, Mi: tmi}
+func paragraphEnd() ResultWord {
+	return ResultWord{Type: "PARAGRAPH_END"}
+}
+
+func num(ts *tagset.Tagset, s string, mi string) ResultWord {
+	return ResultWord{Type: "NUMBER", String: s, Mi: ts.NumberMi(mi)} // negative number workaround
 }
 
 func word(s, mf, mi string) ResultWord {
 	return ResultWord{Type: "WORD", String: s, Lemma: mf, Mi: mi}
 }
 
-func isSep(mi string) bool {
-	return strings.HasPrefix(mi, "T")
+func isSep(ts *tagset.Tagset, mi string) bool {
+	return ts.IsSeparator(mi)
 }
 
-func isNum(s string, mi string) bool {
-	return mi == "M----rn" || mi == "M----d-" ||
-		((mi == "Th" || mi == "X-") && len(s) > 1 && utils.IsNumber(s)) // negative number workaround
+func isNum(ts *tagset.Tagset, s string, mi string) bool {
+	return ts.IsNumber(mi) ||
+		(ts.IsNumberCandidate(mi) && len(s) > 1 && utils.IsNumber(s)) // negative number workaround
 }
@@ -1,16 +1,26 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/backend"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/jobs"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/tagset"
+	"github.com/airenas/lt-pos-tagger/internal/pkg/tokenizer"
 	"github.com/airenas/lt-pos-tagger/internal/pkg/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -27,6 +37,12 @@ func initTest(t *testing.T) {
 	tResp = httptest.NewRecorder()
 }
 
+//initBatchTest uses the backends making the results by the text as the joined batch texts need it
+func initBatchTest(t *testing.T) {
+	initTest(t)
+	tData.Segmenter, tData.Tagger = &wordLex{}, &wordTagger{}
+}
+
 func TestLive(t *testing.T) {
 	initTest(t)
 	req := httptest.NewRequest(http.MethodGet, "/live", nil)
@@ -36,6 +52,28 @@ func TestLive(t *testing.T) {
 	assert.Equal(t, `{"service":"OK"}`, tResp.Body.String())
 }
 
+func TestStatus(t *testing.T) {
+	initTest(t)
+	tData.Backends = []Backend{&testBackend{status: backend.Status{Name: "lex", Breaker: "open", Limit: 2, InFlight: 1,
+		Endpoints: []backend.EndpointStatus{{URL: "http://lex", Up: true, Outstanding: 1}}}}}
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+
+	tEcho.ServeHTTP(tResp, req)
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.JSONEq(t, `{"backends":[{"name":"lex","breaker":"open","limit":2,"inFlight":1,`+
+		`"endpoints":[{"url":"http://lex","up":true,"outstanding":1}]}]}`,
+		tResp.Body.String())
+}
+
+func TestStatus_Empty(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+
+	tEcho.ServeHTTP(tResp, req)
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.JSONEq(t, `{"backends":[]}`, tResp.Body.String())
+}
+
 func TestNotFound(t *testing.T) {
 	initTest(t)
 	req := httptest.NewRequest(http.MethodGet, "/any", strings.NewReader(``))
@@ -53,9 +91,354 @@ func TestProvides(t *testing.T) {
 	tEcho.ServeHTTP(tResp, req)
 
 	assert.Equal(t, http.StatusOK, tResp.Code)
-	assert.Equal(t, `[{"type":"WORD","string":"mama","mi":"mama","lemma":"xxxx"},{"type":"SPACE","string":" "},{"type":"WORD","string":"o","mi":".","lemma":"xxx"},{"type":"SENTENCE_END"}]`,
+	assert.Equal(t, `[{"type":"WORD","string":"mama","mi":"mama","lemma":"xxxx","invalidMi":true},{"type":"SPACE","string":" "},{"type":"WORD","string":"o","mi":".","lemma":"xxx","invalidMi":true},{"type":"SENTENCE_END"}]`,
+		strings.TrimSpace(tResp.Body.String()))
+
+}
+
+func TestProvides_Alternatives(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag?alternatives=true", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, `[{"type":"WORD","string":"mama","mi":"mama","lemma":"xxxx","invalidMi":true,`+
+		`"alternatives":[{"lemma":"xxxx","mi":"mama","selected":true},{"lemma":"xxxx","mi":"."}]},`+
+		`{"type":"SPACE","string":" "},{"type":"WORD","string":"o","mi":".","lemma":"xxx","invalidMi":true,`+
+		`"alternatives":[{"lemma":"xxx","mi":".","selected":true}]},{"type":"SENTENCE_END"}]`,
+		strings.TrimSpace(tResp.Body.String()))
+}
+
+func TestProvides_Offsets(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag?offsets=1", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Contains(t, tResp.Body.String(), `{"type":"SPACE","string":" ","span":{"offset":4,"length":1,"byteOffset":4,"byteLength":1}}`)
+}
+
+func TestProvides_Document(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag?format=document", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, `{"paragraphs":[{"id":1,"sentences":[{"id":1,"tokens":[`+
+		`{"id":1,"type":"WORD","string":"mama","mi":"mama","lemma":"xxxx","invalidMi":true},{"id":2,"type":"SPACE","string":" "},`+
+		`{"id":3,"type":"WORD","string":"o","mi":".","lemma":"xxx","invalidMi":true}]}]}]}`,
+		strings.TrimSpace(tResp.Body.String()))
+}
+
+func TestProvides_DocumentAccept(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+	req.Header.Set(echo.HeaderAccept, "application/vnd.tagger.document+json")
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.True(t, strings.HasPrefix(tResp.Body.String(), `{"paragraphs":[`))
+}
+
+func TestProvides_CoNLLU(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+	req.Header.Set(echo.HeaderAccept, "text/x-conllu")
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, "text/x-conllu; charset=UTF-8", tResp.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "# newpar id = 1\n# sent_id = 1\n# text = mama o\n"+
+		"1\tmama\txxxx\tX\tmama\t_\t_\t_\t_\t_\n"+
+		"2\to\txxx\tX\t.\t_\t_\t_\t_\t_\n\n", tResp.Body.String())
+}
+
+func TestFails_WrongFormat(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag?format=olia", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusBadRequest, tResp.Code)
+}
+
+func TestFails_WrongParam(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag?alternatives=olia", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusBadRequest, tResp.Code)
+}
+
+func TestBatch(t *testing.T) {
+	initBatchTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag/batch",
+		strings.NewReader(`[{"id":"1","text":"mama o"},{"id":"2","text":"  "},{"id":"3","text":"Tėtis."},{"id":"4","text":"mama o "}]`))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, `[{"id":"1","result":[{"type":"WORD","string":"mama","mi":"X-","lemma":"mama"},`+
+		`{"type":"SPACE","string":" "},{"type":"WORD","string":"o","mi":"X-","lemma":"o"},{"type":"SENTENCE_END"}]},`+
+		`{"id":"2","error":"No input"},`+
+		`{"id":"3","result":[{"type":"WORD","string":"Tėtis","mi":"X-","lemma":"Tėtis"},`+
+		`{"type":"WORD","string":".","mi":"X-","lemma":"."},{"type":"SENTENCE_END"}]},`+
+		`{"id":"4","result":[{"type":"WORD","string":"mama","mi":"X-","lemma":"mama"},`+
+		`{"type":"SPACE","string":" "},{"type":"WORD","string":"o","mi":"X-","lemma":"o"},{"type":"SENTENCE_END"}]}]`,
 		strings.TrimSpace(tResp.Body.String()))
+	assert.Equal(t, 1, tData.Segmenter.(*wordLex).calls)
+	assert.Equal(t, 1, tData.Tagger.(*wordTagger).calls)
+}
+
+func TestProcessBatch_SameAsSeparate(t *testing.T) {
+	initBatchTest(t)
+	items := []BatchItem{{ID: "1", Text: "Mama su kasa.\nKasa kasa smėlį."}, {ID: "2", Text: "Labas. Kaip sekasi?"},
+		{ID: "3", Text: "ąž ėė"}}
+	opt := &Options{Format: formatDocument}
+	var exp []BatchResult
+	for _, it := range items {
+		pr, err := process(context.Background(), tData, it.Text, opt, nil)
+		require.Nil(t, err)
+		exp = append(exp, BatchResult{ID: it.ID, Result: formatResult(pr, opt)})
+	}
+	tData.Segmenter.(*wordLex).calls = 0
+
+	res := processBatch(context.Background(), tData, items, opt)
 
+	assert.Equal(t, exp, res)
+	assert.Equal(t, 1, tData.Segmenter.(*wordLex).calls)
+}
+
+func TestProcessBatch_JoinLen(t *testing.T) {
+	initBatchTest(t)
+	tData.BatchJoinLen = 15
+	items := []BatchItem{{ID: "1", Text: "mama o"}, {ID: "2", Text: "mama o"}, {ID: "3", Text: "mama o"},
+		{ID: "4", Text: "ilgas tekstas be galo"}}
+
+	res := processBatch(context.Background(), tData, items, &Options{})
+
+	for _, r := range res {
+		assert.Empty(t, r.Error)
+	}
+	assert.Equal(t, 3, tData.Segmenter.(*wordLex).calls)
+	assert.Equal(t, 3, tData.Tagger.(*wordTagger).calls)
+}
+
+func TestProcessBatch_Cache(t *testing.T) {
+	initBatchTest(t)
+	tData.Cache = &testCache{items: map[string]*api.Analysis{}}
+	items := []BatchItem{{ID: "1", Text: "mama o"}, {ID: "2", Text: "tėtis"}}
+	res := processBatch(context.Background(), tData, items, &Options{})
+	assert.Equal(t, 2, len(tData.Cache.(*testCache).items))
+
+	res2 := processBatch(context.Background(), tData, items, &Options{})
+
+	assert.Equal(t, res, res2)
+	assert.Equal(t, 1, tData.Segmenter.(*wordLex).calls)
+}
+
+func TestProcessBatch_Fails(t *testing.T) {
+	initBatchTest(t)
+	tData.Tagger = &testTagger{err: utils.ErrTooBusy}
+	items := []BatchItem{{ID: "1", Text: "mama o"}, {ID: "2", Text: "tėtis"}}
+
+	res := processBatch(context.Background(), tData, items, &Options{})
+
+	assert.Equal(t, []BatchResult{{ID: "1", Error: "Can't tag"}, {ID: "2", Error: "Can't tag"}}, res)
+}
+
+func TestBatch_CoNLLU(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag/batch?format=conllu", strings.NewReader(`[{"id":"1","text":"mama o"}]`))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Contains(t, tResp.Body.String(), `"result":"# newpar id = 1\n# sent_id = 1\n# text = mama o\n`)
+}
+
+func TestBatch_Fails(t *testing.T) {
+	tests := []struct {
+		v string
+		i string
+	}{
+		{v: ``, i: "empty"},
+		{v: `[]`, i: "no items"},
+		{v: `{"id":"1"}`, i: "not array"},
+		{v: `[{"id":"1","text":"a"},{"id":"2","text":"a"},{"id":"3","text":"a"}]`, i: "too many"},
+		{v: `[{"id":"1","text":"ąčę"},{"id":"2","text":"ąčę"}]`, i: "too long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.i, func(t *testing.T) {
+			initTest(t)
+			tData.BatchMaxItems = 2
+			tData.BatchMaxLen = 5
+			req := httptest.NewRequest(http.MethodPost, "/tag/batch", strings.NewReader(tt.v))
+
+			tEcho.ServeHTTP(tResp, req)
+
+			assert.Equal(t, http.StatusBadRequest, tResp.Code)
+		})
+	}
+}
+
+func TestBatch_FailsMorph(t *testing.T) {
+	initTest(t)
+	tData.Tagger = &testTagger{err: utils.ErrTooBusy}
+	req := httptest.NewRequest(http.MethodPost, "/tag/batch", strings.NewReader(`[{"id":"1","text":"mama o"}]`))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, `[{"id":"1","error":"Can't tag"}]`, strings.TrimSpace(tResp.Body.String()))
+}
+
+func TestProcessBatch_Order(t *testing.T) {
+	initBatchTest(t)
+	tData.BatchWorkers = 3
+	items := make([]BatchItem, 20)
+	for i := range items {
+		items[i] = BatchItem{ID: strconv.Itoa(i), Text: "mama o"}
+	}
+	res := processBatch(context.Background(), tData, items, &Options{})
+	if assert.Equal(t, 20, len(res)) {
+		for i, r := range res {
+			assert.Equal(t, strconv.Itoa(i), r.ID)
+			assert.Empty(t, r.Error)
+		}
+	}
+}
+
+func TestProvides_JSON(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag?offsets=1",
+		strings.NewReader(`{"text":"mama o", "options":{"skipSpaces":true, "lemmaCase":"upper", "offsets":false}}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSONCharsetUTF8)
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, `[{"type":"WORD","string":"mama","mi":"mama","lemma":"XXXX","invalidMi":true},`+
+		`{"type":"WORD","string":"o","mi":".","lemma":"XXX","invalidMi":true},{"type":"SENTENCE_END"}]`,
+		strings.TrimSpace(tResp.Body.String()))
+}
+
+func TestProvides_JSONFormat(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader(`{"text":"mama o", "options":{"format":"conllu"}}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, "text/x-conllu; charset=UTF-8", tResp.Header().Get(echo.HeaderContentType))
+}
+
+func TestProvides_TextAsJSON(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+}
+
+func TestFails_JSON(t *testing.T) {
+	tests := []struct {
+		v string
+		i string
+	}{
+		{v: `{"text":"mama o", "options":{"olia":true}}`, i: "unknown option"},
+		{v: `{"text":"mama o", "olia":true}`, i: "unknown field"},
+		{v: `{"text":"mama o", "options":{"offsets":"a"}}`, i: "wrong type"},
+		{v: `{"text":"mama o", "options":{"format":"a"}}`, i: "wrong format"},
+		{v: `{"text":"mama o", "options":{"lemmaCase":"a"}}`, i: "wrong lemma case"},
+		{v: `{"text":" ", "options":{}}`, i: "no text"},
+		{v: `{"text":"mama o", "options":{"offsets":true,}}`, i: "trailing comma"},
+		{v: `{"text":"mama o", "options":{`, i: "truncated"},
+		{v: ` {text:"mama o"}`, i: "not quoted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.i, func(t *testing.T) {
+			initTest(t)
+			req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader(tt.v))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+			tEcho.ServeHTTP(tResp, req)
+
+			assert.Equal(t, http.StatusBadRequest, tResp.Code)
+		})
+	}
+}
+
+func TestJobs(t *testing.T) {
+	initTest(t)
+	jm, err := jobs.NewManager(1, 10, time.Minute)
+	require.Nil(t, err)
+	defer jm.Close()
+	tData.Jobs = jm
+	tEcho = initRoutes(tData)
+
+	req := httptest.NewRequest(http.MethodPost, "/jobs?format=document", strings.NewReader("mama o"))
+	tEcho.ServeHTTP(tResp, req)
+	require.Equal(t, http.StatusAccepted, tResp.Code)
+	var info jobs.Info
+	require.Nil(t, json.Unmarshal(tResp.Body.Bytes(), &info))
+	assert.Equal(t, "/jobs/"+info.ID, tResp.Header().Get(echo.HeaderLocation))
+
+	for i := 0; i < 100 && info.Status != jobs.StatusDone; i++ {
+		time.Sleep(5 * time.Millisecond)
+		tResp = httptest.NewRecorder()
+		tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodGet, "/jobs/"+info.ID, nil))
+		require.Equal(t, http.StatusOK, tResp.Code)
+		require.Nil(t, json.Unmarshal(tResp.Body.Bytes(), &info))
+	}
+	require.Equal(t, jobs.StatusDone, info.Status)
+
+	tResp = httptest.NewRecorder()
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodGet, "/jobs/"+info.ID+"/result", nil))
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.True(t, strings.HasPrefix(tResp.Body.String(), `{"paragraphs":[`))
+}
+
+func TestJobs_Fails(t *testing.T) {
+	initTest(t)
+	tData.Jobs = &testJobs{info: &jobs.Info{ID: "1", Status: jobs.StatusFailed, Error: "olia"}}
+	tEcho = initRoutes(tData)
+
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodGet, "/jobs/2", nil))
+	assert.Equal(t, http.StatusNotFound, tResp.Code)
+	tResp = httptest.NewRecorder()
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodGet, "/jobs/2/result", nil))
+	assert.Equal(t, http.StatusNotFound, tResp.Code)
+	tResp = httptest.NewRecorder()
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodGet, "/jobs/1/result", nil))
+	assert.Equal(t, http.StatusConflict, tResp.Code)
+	tData.Jobs.(*testJobs).info.Status = jobs.StatusWorking
+	tResp = httptest.NewRecorder()
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodGet, "/jobs/1/result", nil))
+	assert.Equal(t, http.StatusConflict, tResp.Code)
+
+	tResp = httptest.NewRecorder()
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader("")))
+	assert.Equal(t, http.StatusBadRequest, tResp.Code)
+	tData.Jobs.(*testJobs).err = utils.ErrTooBusy
+	tResp = httptest.NewRecorder()
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader("mama o")))
+	assert.Equal(t, http.StatusTooManyRequests, tResp.Code)
+}
+
+func TestJobs_Disabled(t *testing.T) {
+	initTest(t)
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader("mama o")))
+	assert.Equal(t, http.StatusNotFound, tResp.Code)
 }
 
 func TestFails_Empty(t *testing.T) {
@@ -76,6 +459,171 @@ func TestFails_Map(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, tResp.Code)
 }
 
+func TestProvides_Cache(t *testing.T) {
+	initTest(t)
+	tc := &testCache{items: make(map[string]*api.Analysis)}
+	tData.Cache = tc
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o")))
+	require.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, 1, len(tc.items))
+
+	tData.Segmenter = &testLex{err: errors.New("err")}
+	resp := httptest.NewRecorder()
+	tEcho.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o")))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, strings.TrimSpace(tResp.Body.String()), strings.TrimSpace(resp.Body.String()))
+}
+
+func TestProvides_NoCache(t *testing.T) {
+	initTest(t)
+	tc := &testCache{items: make(map[string]*api.Analysis)}
+	tData.Cache = tc
+	tEcho.ServeHTTP(tResp, httptest.NewRequest(http.MethodPost, "/tag?noCache=true", strings.NewReader("mama o")))
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, 0, len(tc.items))
+}
+
+func TestProvides_PassesContext(t *testing.T) {
+	initTest(t)
+	ctx, cancelF := context.WithCancel(context.Background())
+	defer cancelF()
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o")).WithContext(ctx)
+	tEcho.ServeHTTP(tResp, req)
+	require.Equal(t, http.StatusOK, tResp.Code)
+	cancelF()
+	require.NotNil(t, tData.Segmenter.(*testLex).ctx)
+	assert.NotNil(t, tData.Segmenter.(*testLex).ctx.Err())
+	require.NotNil(t, tData.Tagger.(*testTagger).ctx)
+	assert.NotNil(t, tData.Tagger.(*testTagger).ctx.Err())
+}
+
+func TestProvides_FallbackSegmenter(t *testing.T) {
+	initTest(t)
+	tc := &testCache{items: make(map[string]*api.Analysis)}
+	tData.Cache = tc
+	tData.FallbackSegmenter = tData.Segmenter
+	tData.Segmenter = &testLex{err: errors.Wrap(utils.ErrBackendFailed, "err")}
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, "segmentation=lex is unavailable", tResp.Header().Get(headerDegraded))
+	assert.Equal(t, 0, len(tc.items))
+}
+
+func TestProvides_FallbackSegmenterDocument(t *testing.T) {
+	initTest(t)
+	tData.FallbackSegmenter = tData.Segmenter
+	tData.Segmenter = &testLex{err: errors.Wrap(utils.ErrBackendFailed, "err")}
+	req := httptest.NewRequest(http.MethodPost, "/tag?format=document", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Contains(t, tResp.Body.String(), `"degraded":{"segmentation":"lex is unavailable"}`)
+}
+
+func TestProvides_NotDegraded(t *testing.T) {
+	initTest(t)
+	tData.FallbackSegmenter = &testLex{err: errors.New("err")}
+	req := httptest.NewRequest(http.MethodPost, "/tag?format=document", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, "", tResp.Header().Get(headerDegraded))
+	assert.NotContains(t, tResp.Body.String(), `degraded`)
+}
+
+func TestFailsLex_NoFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "too busy", err: utils.ErrTooBusy, code: http.StatusTooManyRequests},
+		{name: "4xx", err: errors.New("can't invoke lex: 400"), code: http.StatusInternalServerError},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			initTest(t)
+			tData.FallbackSegmenter = tData.Segmenter
+			tData.Segmenter = &testLex{err: tc.err}
+			req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+
+			tEcho.ServeHTTP(tResp, req)
+
+			assert.Equal(t, tc.code, tResp.Code)
+			assert.Equal(t, "", tResp.Header().Get(headerDegraded))
+		})
+	}
+}
+
+func TestProvides_FallbackSegmenterUnavailable(t *testing.T) {
+	initTest(t)
+	tData.FallbackSegmenter = tData.Segmenter
+	tData.Segmenter = &testLex{err: utils.ErrBackendUnavailable}
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, "segmentation=lex is unavailable", tResp.Header().Get(headerDegraded))
+}
+
+func TestFailsFallbackSegmenter(t *testing.T) {
+	initTest(t)
+	tData.FallbackSegmenter = &testLex{err: errors.New("err")}
+	tData.Segmenter = &testLex{err: utils.ErrBackendUnavailable}
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, tResp.Code)
+}
+
+func TestProvides_DegradedTagging(t *testing.T) {
+	initTest(t)
+	tc := &testCache{items: make(map[string]*api.Analysis)}
+	tData.Cache = tc
+	tData.Tagger = &testTagger{err: errors.New("err")}
+	req := httptest.NewRequest(http.MethodPost, "/tag?allowDegraded=true", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, "tagging=morph is unavailable", tResp.Header().Get(headerDegraded))
+	assert.Equal(t, `[{"type":"WORD","string":"mama"},{"type":"SPACE","string":" "},{"type":"WORD","string":"o"},{"type":"SENTENCE_END"}]`,
+		strings.TrimSpace(tResp.Body.String()))
+	assert.Equal(t, 0, len(tc.items))
+}
+
+func TestProvides_DegradedBoth(t *testing.T) {
+	initTest(t)
+	tData.FallbackSegmenter = tData.Segmenter
+	tData.Segmenter = &testLex{err: errors.Wrap(utils.ErrBackendFailed, "err")}
+	tData.Tagger = &testTagger{err: errors.New("err")}
+	req := httptest.NewRequest(http.MethodPost, "/tag?allowDegraded=true&format=document", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusOK, tResp.Code)
+	assert.Equal(t, "segmentation=lex is unavailable; tagging=morph is unavailable", tResp.Header().Get(headerDegraded))
+	assert.Contains(t, tResp.Body.String(),
+		`"degraded":{"segmentation":"lex is unavailable","tagging":"morph is unavailable"}`)
+}
+
+func TestFailsMorph_NotAllowedDegraded(t *testing.T) {
+	initTest(t)
+	tData.Tagger = &testTagger{err: errors.New("err")}
+	req := httptest.NewRequest(http.MethodPost, "/tag", strings.NewReader("mama o"))
+
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, tResp.Code)
+}
+
 func TestFailsMorph(t *testing.T) {
 	initTest(t)
 	req := httptest.NewRequest("POST", "/tag", strings.NewReader("mama o"))
@@ -116,6 +664,26 @@ func TestFailsLex_TooBusy(t *testing.T) {
 	assert.Equal(t, http.StatusTooManyRequests, tResp.Code)
 }
 
+func TestFailsMorph_Unavailable(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest("POST", "/tag", strings.NewReader("mama o"))
+
+	tData.Tagger = &testTagger{err: utils.ErrBackendUnavailable}
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, tResp.Code)
+}
+
+func TestFailsLex_Unavailable(t *testing.T) {
+	initTest(t)
+	req := httptest.NewRequest("POST", "/tag", strings.NewReader("mama o"))
+
+	tData.Segmenter = &testLex{err: utils.ErrBackendUnavailable}
+	tEcho.ServeHTTP(tResp, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, tResp.Code)
+}
+
 func TestMapOK(t *testing.T) {
 	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}}
 	tr := &api.TaggerResult{Msd: [][][]string{{{"mama", "xxxx"}}}}
@@ -264,6 +832,130 @@ func TestMap_SeparatorOneSymbol(t *testing.T) {
 	assert.Equal(t, "Th", r[0].Mi)
 }
 
+func TestMapAlternatives(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}, {5, 1}}, S: [][]int{{0, 6}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"1234", "M----d-"}, {"1234", "X-"}}, {{".", "T."}, {".", "X-"}}}}
+	r, err := mapRes("1234 .", tr, sr, tagset.Default(), &Options{Alternatives: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(r))
+	assert.Equal(t, []Alternative{{Lemma: "1234", Mi: "M----d-", Selected: true}, {Lemma: "1234", Mi: "X-"}}, r[0].Alternatives)
+	assert.Nil(t, r[2].Alternatives)
+}
+
+func TestMapAlternatives_NumberMi(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"1234", "Th"}, {"1234", "X-"}}}}
+	r, err := mapRes("1234", tr, sr, tagset.Default(), &Options{Alternatives: true})
+	require.Nil(t, err)
+	assert.Equal(t, "NUMBER", r[0].Type)
+	assert.Equal(t, "M----d-", r[0].Mi)
+	assert.Equal(t, []Alternative{{Lemma: "1234", Mi: "M----d-", Selected: true}, {Lemma: "1234", Mi: "X-"}}, r[0].Alternatives)
+}
+
+func TestMapAlternatives_Error(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"mama", "xxxx"}, {"mama"}}}}
+	_, err := mapRes("mama", tr, sr, tagset.Default(), &Options{Alternatives: true})
+	assert.NotNil(t, err)
+	_, err = mapRes("mama", tr, sr, tagset.Default(), &Options{})
+	assert.Nil(t, err)
+}
+
+func TestMapOffsets(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}, {5, 2}}, S: [][]int{{0, 7}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"mama", "xxxx"}}, {{"oo", "xoo"}}}}
+	r, err := mapRes("mamą oš", tr, sr, tagset.Default(), &Options{Offsets: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(r))
+	assert.Equal(t, &Span{Offset: 0, Length: 4, ByteOffset: 0, ByteLength: 5}, r[0].Span)
+	assert.Equal(t, &Span{Offset: 4, Length: 1, ByteOffset: 5, ByteLength: 1}, r[1].Span)
+	assert.Equal(t, &Span{Offset: 5, Length: 2, ByteOffset: 6, ByteLength: 3}, r[2].Span)
+	assert.Equal(t, &Span{Offset: 7, Length: 0, ByteOffset: 9, ByteLength: 0}, r[3].Span)
+}
+
+func TestMapUD(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}, {5, 1}}, S: [][]int{{0, 6}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"mama", "Ncfsnn-"}}, {{".", "T."}}}}
+	r, err := mapRes("Mama .", tr, sr, tagset.Default(), &Options{UD: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(r))
+	assert.Equal(t, "NOUN", r[0].Upos)
+	assert.Equal(t, "Case=Nom|Gender=Fem|Number=Sing", r[0].Feats)
+	assert.Equal(t, "", r[1].Upos)
+	assert.Equal(t, "PUNCT", r[2].Upos)
+	assert.Equal(t, "", r[2].Feats)
+}
+
+func TestMapFeatures(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"mama", "Ncfsnn-"}}}}
+	r, err := mapRes("Mama", tr, sr, tagset.Default(), &Options{Features: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(r))
+	if assert.NotNil(t, r[0].Features) {
+		assert.Equal(t, "noun", r[0].Features.POS.EN)
+		assert.Equal(t, "nominative", r[0].Features.Attributes["case"].EN)
+	}
+	assert.Nil(t, r[1].Features)
+}
+
+func TestMapInvalidMi(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}, {5, 1}, {7, 2}}, S: [][]int{{0, 9}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"mama", "Ncfsnn-"}}, {{".", "T."}}, {{"-1", "Th"}}}}
+	r, err := mapRes("Mama . -1", tr, sr, tagset.Default(), &Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(r))
+	assert.False(t, r[0].InvalidMi)
+	assert.False(t, r[2].InvalidMi)
+	assert.False(t, r[4].InvalidMi)
+	tr = &api.TaggerResult{Msd: [][][]string{{{"mama", "Ncfsnnz"}}, {{".", "Z."}}, {{"-1", "Th"}}}}
+	r, err = mapRes("Mama . -1", tr, sr, tagset.Default(), &Options{})
+	assert.Nil(t, err)
+	assert.True(t, r[0].InvalidMi)
+	assert.True(t, r[2].InvalidMi)
+	assert.False(t, r[4].InvalidMi)
+}
+
+func TestMapTagsetClasses(t *testing.T) {
+	ts, err := tagset.Parse([]byte(`{"classes":{"separator":{"prefixes":["S"]}, "number":{"tags":["N"]}},` +
+		`"categories":[{"code":"N", "upos":"NUM"}, {"code":"S", "upos":"PUNCT"}]}`))
+	assert.Nil(t, err)
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 2}, {3, 1}, {5, 1}}, S: [][]int{{0, 6}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"10", "N"}}, {{".", "S"}}, {{"-", "T."}}}}
+	r, err := mapRes("10 . -", tr, sr, ts, &Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(r))
+	assert.Equal(t, "NUMBER", r[0].Type)
+	assert.Equal(t, "SEPARATOR", r[2].Type)
+	assert.Equal(t, "WORD", r[4].Type)
+	assert.True(t, r[4].InvalidMi)
+}
+
+func TestMapLemmaCase(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"Mama", "Ncfsnn-"}, {"mamas", "Ncfsnn-"}}}}
+	r, err := mapRes("Mama", tr, sr, tagset.Default(), &Options{LemmaCase: "lower", Alternatives: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "mama", r[0].Lemma)
+	assert.Equal(t, "mamas", r[0].Alternatives[1].Lemma)
+	r, err = mapRes("Mama", tr, sr, tagset.Default(), &Options{LemmaCase: "upper", Alternatives: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "MAMA", r[0].Lemma)
+	assert.Equal(t, "MAMAS", r[0].Alternatives[1].Lemma)
+	r, err = mapRes("Mama", tr, sr, tagset.Default(), &Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "Mama", r[0].Lemma)
+}
+
+func TestMapNoTags(t *testing.T) {
+	r, err := mapRes("Mama, 10 ąž.", nil, &api.SegmenterResult{Seg: [][]int{{0, 4}, {4, 1}, {6, 2}, {9, 2}, {11, 1}},
+		S: [][]int{{0, 12}}}, tagset.Default(), &Options{SkipSpaces: true, UD: true})
+	require.Nil(t, err)
+	assert.Equal(t, []ResultWord{{Type: "WORD", String: "Mama"}, {Type: "SEPARATOR", String: ","},
+		{Type: "NUMBER", String: "10"}, {Type: "WORD", String: "ąž"}, {Type: "SEPARATOR", String: "."},
+		{Type: "SENTENCE_END"}}, r)
+}
+
 func TestMapSentence(t *testing.T) {
 	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}}
 	tr := &api.TaggerResult{Msd: [][][]string{{{"1234", "M----d-"}}}}
@@ -283,6 +975,30 @@ func TestMapSentenceSeveral(t *testing.T) {
 	assert.Equal(t, "SENTENCE_END", r[4].Type)
 }
 
+func TestMapParagraphs(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}, {5, 5}, {12, 1}}, S: [][]int{{0, 4}, {5, 5}, {12, 1}},
+		P: [][]int{{0, 10}, {12, 1}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"1234", "M----d-"}}, {{"12345", "M----d-"}}, {{"1", "M----d-"}}}}
+	r, err := mapRes("1234 12345\n\n1", tr, sr, tagset.Default(), &Options{Paragraphs: true})
+	assert.Nil(t, err)
+	if assert.Equal(t, 10, len(r)) {
+		assert.Equal(t, "SENTENCE_END", r[1].Type)
+		assert.Equal(t, "SENTENCE_END", r[4].Type)
+		assert.Equal(t, "PARAGRAPH_END", r[5].Type)
+		assert.Equal(t, "SPACE", r[6].Type)
+		assert.Equal(t, "SENTENCE_END", r[8].Type)
+		assert.Equal(t, "PARAGRAPH_END", r[9].Type)
+	}
+}
+
+func TestMapParagraphs_Skip(t *testing.T) {
+	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}, P: [][]int{{0, 4}}}
+	tr := &api.TaggerResult{Msd: [][][]string{{{"1234", "M----d-"}}}}
+	r, err := mapRes("1234", tr, sr, tagset.Default(), &Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(r))
+}
+
 func TestMapErrTooLongSeg(t *testing.T) {
 	sr := &api.SegmenterResult{Seg: [][]int{{0, 4}}, S: [][]int{{0, 4}}}
 	tr := &api.TaggerResult{Msd: [][][]string{{{"1234", "M----d-"}}}}
@@ -341,6 +1057,55 @@ func TestMapSentence_ErrorNoSentence(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestMakeDocument(t *testing.T) {
+	d := makeDocument([]ResultWord{{Type: "WORD", String: "a"}, {Type: "SENTENCE_END"}, {Type: "SPACE", String: " "},
+		{Type: "WORD", String: "b"}, {Type: "SENTENCE_END"}, {Type: "PARAGRAPH_END"}, {Type: "SPACE", String: "\n"},
+		{Type: "WORD", String: "c"}, {Type: "SENTENCE_END"}, {Type: "PARAGRAPH_END"}})
+	if assert.Equal(t, 2, len(d.Paragraphs)) {
+		assert.Equal(t, 1, d.Paragraphs[0].ID)
+		assert.Equal(t, 2, len(d.Paragraphs[0].Sentences))
+		assert.Equal(t, 2, d.Paragraphs[1].ID)
+		if assert.Equal(t, 1, len(d.Paragraphs[1].Sentences)) {
+			assert.Equal(t, Sentence{ID: 3, Tokens: []ResultWord{{ID: 4, Type: "SPACE", String: "\n"},
+				{ID: 5, Type: "WORD", String: "c"}}}, d.Paragraphs[1].Sentences[0])
+		}
+	}
+}
+
+func TestMakeDocument_NoMarkers(t *testing.T) {
+	d := makeDocument([]ResultWord{{Type: "WORD", String: "a"}})
+	if assert.Equal(t, 1, len(d.Paragraphs)) && assert.Equal(t, 1, len(d.Paragraphs[0].Sentences)) {
+		assert.Equal(t, []ResultWord{{ID: 1, Type: "WORD", String: "a"}}, d.Paragraphs[0].Sentences[0].Tokens)
+	}
+	assert.Equal(t, 0, len(makeDocument(nil).Paragraphs))
+}
+
+func TestToCoNLLU(t *testing.T) {
+	d := makeDocument([]ResultWord{{Type: "WORD", String: "Mama", Lemma: "mama", Mi: "Ncfsnn-"},
+		{Type: "SEPARATOR", String: ",", Mi: "T,"}, {Type: "SPACE", String: " "}, {Type: "WORD", String: "o"},
+		{Type: "SEPARATOR", String: ".", Mi: "T."}, {Type: "SENTENCE_END"}, {Type: "PARAGRAPH_END"}, {Type: "SPACE", String: "\n\n"},
+		{Type: "NUMBER", String: "10", Mi: "M----d-"}, {Type: "SENTENCE_END"}, {Type: "PARAGRAPH_END"}})
+	assert.Equal(t, "# newpar id = 1\n# sent_id = 1\n# text = Mama, o.\n"+
+		"1\tMama\tmama\t_\tNcfsnn-\t_\t_\t_\t_\tSpaceAfter=No\n"+
+		"2\t,\t_\t_\tT,\t_\t_\t_\t_\t_\n"+
+		"3\to\t_\t_\t_\t_\t_\t_\t_\tSpaceAfter=No\n"+
+		"4\t.\t_\t_\tT.\t_\t_\t_\t_\t_\n\n"+
+		"# newpar id = 2\n# sent_id = 2\n# text = 10\n"+
+		"1\t10\t_\t_\tM----d-\t_\t_\t_\t_\t_\n\n", toCoNLLU(d))
+}
+
+func TestToCoNLLU_UD(t *testing.T) {
+	d := makeDocument([]ResultWord{{Type: "WORD", String: "Mama", Lemma: "mama", Mi: "Ncfsnn-", Upos: "NOUN",
+		Feats: "Case=Nom|Gender=Fem|Number=Sing"}})
+	assert.Equal(t, "# newpar id = 1\n# sent_id = 1\n# text = Mama\n"+
+		"1\tMama\tmama\tNOUN\tNcfsnn-\tCase=Nom|Gender=Fem|Number=Sing\t_\t_\t_\t_\n\n", toCoNLLU(d))
+}
+
+func TestToCoNLLU_TextOneLine(t *testing.T) {
+	assert.Equal(t, "a b", conlluText([]ResultWord{{Type: "SPACE", String: " "}, {Type: "WORD", String: "a"},
+		{Type: "SPACE", String: "\n"}, {Type: "WORD", String: "b"}, {Type: "SPACE", String: " "}}))
+}
+
 func TestTryTakeText(t *testing.T) {
 	assert.Equal(t, "", tryTakeText([]rune(""), 10))
 	assert.Equal(t, "aaaaaaaadada", tryTakeText([]rune("aaaaaaaadada"), 0))
@@ -348,23 +1113,80 @@ func TestTryTakeText(t *testing.T) {
 }
 
 type testTagger struct {
-	res *api.TaggerResult
-	err error
+	res  *api.TaggerResult
+	err  error
+	lock sync.Mutex
+	ctx  context.Context
 }
 
-func (s *testTagger) Process(string, *api.SegmenterResult) (*api.TaggerResult, error) {
+func (s *testTagger) Process(ctx context.Context, _ string, _ *api.SegmenterResult) (*api.TaggerResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ctx = ctx
 	return s.res, s.err
 }
 
 type testLex struct {
-	res *api.SegmenterResult
-	err error
+	res  *api.SegmenterResult
+	err  error
+	lock sync.Mutex
+	ctx  context.Context
 }
 
-func (s *testLex) Process(string) (*api.SegmenterResult, error) {
+func (s *testLex) Process(ctx context.Context, _ string) (*api.SegmenterResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ctx = ctx
 	return s.res, s.err
 }
 
+//wordLex segments the text with the local tokenizer and counts the calls
+type wordLex struct {
+	calls int
+	lock  sync.Mutex
+}
+
+func (s *wordLex) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls++
+	return tokenizer.Segment(text), nil
+}
+
+//wordTagger tags every segment with the word as the lemma and counts the calls
+type wordTagger struct {
+	calls int
+	lock  sync.Mutex
+}
+
+func (s *wordTagger) Process(ctx context.Context, text string, data *api.SegmenterResult) (*api.TaggerResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls++
+	rns := []rune(text)
+	res := &api.TaggerResult{}
+	for _, sg := range data.Seg {
+		res.Msd = append(res.Msd, [][]string{{string(rns[sg[0] : sg[0]+sg[1]]), "X-"}})
+	}
+	return res, nil
+}
+
+type testJobs struct {
+	info *jobs.Info
+	err  error
+}
+
+func (s *testJobs) Add(w jobs.Work) (*jobs.Info, error) {
+	return s.info, s.err
+}
+
+func (s *testJobs) Get(id string) (*jobs.Info, interface{}, bool) {
+	if s.info == nil || s.info.ID != id {
+		return nil, nil, false
+	}
+	return s.info, nil, true
+}
+
 type testPreprocessor struct {
 	err error
 }
@@ -372,3 +1194,24 @@ type testPreprocessor struct {
 func (s *testPreprocessor) Process(text string) (string, error) {
 	return text, s.err
 }
+
+type testCache struct {
+	items map[string]*api.Analysis
+}
+
+func (s *testCache) Get(key string) (*api.Analysis, bool) {
+	res, ok := s.items[key]
+	return res, ok
+}
+
+func (s *testCache) Add(key string, value *api.Analysis) {
+	s.items[key] = value
+}
+
+type testBackend struct {
+	status backend.Status
+}
+
+func (b *testBackend) Status() backend.Status {
+	return b.status
+}
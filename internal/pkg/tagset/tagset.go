@@ -0,0 +1,271 @@
+package tagset
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	//Value is a definition of one attribute value
+	Value struct {
+		EN string `json:"en"`
+		LT string `json:"lt"`
+		//UD is the value of the attribute's UD feature
+		UD string `json:"ud,omitempty"`
+		//Feats are additional UD features, ex. Aspect=Hab
+		Feats []string `json:"feats,omitempty"`
+		//UPOS overrides the category UPOS
+		UPOS string `json:"upos,omitempty"`
+	}
+
+	//Attribute is a definition of one MSD position
+	Attribute struct {
+		Name string `json:"name"`
+		//Feature is the UD feature name
+		Feature string `json:"feature,omitempty"`
+		//Any indicates that any value is allowed
+		Any    bool             `json:"any,omitempty"`
+		Values map[string]Value `json:"values,omitempty"`
+	}
+
+	//Category is a definition of MSD tags starting with Code
+	Category struct {
+		Code       string      `json:"code"`
+		Name       string      `json:"name"`
+		LT         string      `json:"lt"`
+		UPOS       string      `json:"upos"`
+		Feats      []string    `json:"feats,omitempty"`
+		Attributes []Attribute `json:"attributes,omitempty"`
+	}
+
+	//Feature is a decoded MSD value
+	Feature struct {
+		Code string `json:"code"`
+		EN   string `json:"en,omitempty"`
+		LT   string `json:"lt,omitempty"`
+	}
+
+	//UnknownFeature is a MSD value not found in the tagset
+	UnknownFeature struct {
+		//Position is an index of the symbol in MSD
+		Position int    `json:"position"`
+		Code     string `json:"code"`
+	}
+
+	//Features are decoded MSD values
+	Features struct {
+		POS *Feature `json:"pos,omitempty"`
+		//Attributes are decoded values by the attribute name
+		Attributes map[string]Feature `json:"attributes,omitempty"`
+		Unknown    []UnknownFeature   `json:"unknown,omitempty"`
+	}
+
+	//Class is a list of MSD tags
+	Class struct {
+		Tags     []string `json:"tags,omitempty"`
+		Prefixes []string `json:"prefixes,omitempty"`
+		//ReplaceWith is the MSD to be used instead of the original tag
+		ReplaceWith string `json:"replaceWith,omitempty"`
+	}
+
+	//Classes define MSD tags for the token type classification
+	Classes struct {
+		Separator Class `json:"separator"`
+		Number    Class `json:"number"`
+		//NumberCandidate tags are numbers only if the token looks like a number.
+		//Morph returns such tags for negative or scientific format numbers
+		NumberCandidate Class `json:"numberCandidate"`
+	}
+
+	//Tagset keeps MSD definitions
+	Tagset struct {
+		Classes    Classes    `json:"classes"`
+		Categories []Category `json:"categories"`
+
+		byCode map[rune]*Category
+	}
+)
+
+var (
+	//go:embed tagset.json
+	defaultData []byte
+
+	defaultTagset *Tagset
+	defaultOnce   sync.Once
+)
+
+//Default returns the embedded tagset
+func Default() *Tagset {
+	defaultOnce.Do(func() {
+		var err error
+		defaultTagset, err = Parse(defaultData)
+		if err != nil {
+			panic(errors.Wrap(err, "can't parse embedded tagset"))
+		}
+	})
+	return defaultTagset
+}
+
+//Load loads tagset JSON definition from file
+func Load(file string) (*Tagset, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't read %s", file)
+	}
+	return Parse(data)
+}
+
+//Parse parses tagset JSON definition
+func Parse(data []byte) (*Tagset, error) {
+	res := &Tagset{}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, errors.Wrap(err, "can't unmarshal tagset")
+	}
+	res.byCode = make(map[rune]*Category)
+	for i := range res.Categories {
+		c := &res.Categories[i]
+		rns := []rune(c.Code)
+		if len(rns) != 1 {
+			return nil, errors.Errorf("wrong category code '%s'", c.Code)
+		}
+		if res.byCode[rns[0]] != nil {
+			return nil, errors.Errorf("duplicate category code '%s'", c.Code)
+		}
+		if c.UPOS == "" {
+			return nil, errors.Errorf("no upos for category '%s'", c.Code)
+		}
+		for _, a := range c.Attributes {
+			for k := range a.Values {
+				if len([]rune(k)) != 1 {
+					return nil, errors.Errorf("wrong value code '%s' for %s.%s", k, c.Code, a.Name)
+				}
+			}
+		}
+		res.byCode[rns[0]] = c
+	}
+	for _, mi := range append(append(res.Classes.Number.Tags, res.Classes.NumberCandidate.Tags...),
+		res.Classes.NumberCandidate.ReplaceWith) {
+		if mi != "" && !res.Validate(mi) {
+			return nil, errors.Errorf("wrong class tag '%s'", mi)
+		}
+	}
+	return res, nil
+}
+
+//Validate checks if all MSD values are defined in the tagset
+func (t *Tagset) Validate(mi string) bool {
+	f := t.Decode(mi)
+	return f.POS != nil && len(f.Unknown) == 0
+}
+
+//IsSeparator checks if MSD belongs to the separator class
+func (t *Tagset) IsSeparator(mi string) bool {
+	return t.Classes.Separator.has(mi)
+}
+
+//IsNumber checks if MSD belongs to the number class
+func (t *Tagset) IsNumber(mi string) bool {
+	return t.Classes.Number.has(mi)
+}
+
+//IsNumberCandidate checks if MSD belongs to the number candidate class
+func (t *Tagset) IsNumberCandidate(mi string) bool {
+	return t.Classes.NumberCandidate.has(mi)
+}
+
+//NumberMi returns MSD for a number token
+func (t *Tagset) NumberMi(mi string) string {
+	if t.Classes.NumberCandidate.ReplaceWith != "" && t.IsNumberCandidate(mi) {
+		return t.Classes.NumberCandidate.ReplaceWith
+	}
+	return mi
+}
+
+func (c *Class) has(mi string) bool {
+	for _, s := range c.Tags {
+		if s == mi {
+			return true
+		}
+	}
+	for _, s := range c.Prefixes {
+		if strings.HasPrefix(mi, s) {
+			return true
+		}
+	}
+	return false
+}
+
+//ToUD converts MSD to Universal Dependencies UPOS and FEATS string.
+//FEATS are sorted and separated by '|' as required by CoNLL-U. Unknown MSD positions are skipped
+func (t *Tagset) ToUD(mi string) (string, string) {
+	rns := []rune(mi)
+	if len(rns) == 0 {
+		return "", ""
+	}
+	c := t.byCode[rns[0]]
+	if c == nil {
+		return "X", ""
+	}
+	upos := c.UPOS
+	feats := append([]string{}, c.Feats...)
+	for i, r := range rns[1:] {
+		if r == '-' || i >= len(c.Attributes) {
+			continue
+		}
+		a := c.Attributes[i]
+		v, ok := a.Values[string(r)]
+		if !ok {
+			continue
+		}
+		if v.UPOS != "" {
+			upos = v.UPOS
+		}
+		if a.Feature != "" && v.UD != "" {
+			feats = append(feats, a.Feature+"="+v.UD)
+		}
+		feats = append(feats, v.Feats...)
+	}
+	sort.Slice(feats, func(i, j int) bool { return strings.ToLower(feats[i]) < strings.ToLower(feats[j]) })
+	return upos, strings.Join(feats, "|")
+}
+
+//Decode decodes MSD into features. Values not defined in the tagset are reported in Features.Unknown
+func (t *Tagset) Decode(mi string) *Features {
+	res := &Features{}
+	rns := []rune(mi)
+	if len(rns) == 0 {
+		return res
+	}
+	c := t.byCode[rns[0]]
+	if c == nil {
+		res.Unknown = append(res.Unknown, UnknownFeature{Position: 0, Code: string(rns[0])})
+		return res
+	}
+	res.POS = &Feature{Code: c.Code, EN: c.Name, LT: c.LT}
+	for i, r := range rns[1:] {
+		if r == '-' {
+			continue
+		}
+		if i >= len(c.Attributes) {
+			res.Unknown = append(res.Unknown, UnknownFeature{Position: i + 1, Code: string(r)})
+			continue
+		}
+		a := c.Attributes[i]
+		v, ok := a.Values[string(r)]
+		if !ok && !a.Any {
+			res.Unknown = append(res.Unknown, UnknownFeature{Position: i + 1, Code: string(r)})
+			continue
+		}
+		if res.Attributes == nil {
+			res.Attributes = make(map[string]Feature)
+		}
+		res.Attributes[a.Name] = Feature{Code: string(r), EN: v.EN, LT: v.LT}
+	}
+	return res
+}
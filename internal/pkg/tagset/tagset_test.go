@@ -0,0 +1,157 @@
+package tagset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefault(t *testing.T) {
+	assert.NotNil(t, Default())
+	assert.Equal(t, Default(), Default())
+}
+
+func TestParse_Fail(t *testing.T) {
+	tests := []struct {
+		v string
+		i string
+	}{
+		{v: `{"categories":[}`, i: "json"},
+		{v: `{"categories":[{"code":"NN", "upos":"NOUN"}]}`, i: "code"},
+		{v: `{"categories":[{"code":"N", "upos":"NOUN"}, {"code":"N", "upos":"NOUN"}]}`, i: "duplicate"},
+		{v: `{"categories":[{"code":"N"}]}`, i: "upos"},
+		{v: `{"categories":[{"code":"N", "upos":"NOUN", "attributes":[{"name":"a", "values":{"aa":{}}}]}]}`, i: "value"},
+		{v: `{"classes":{"number":{"tags":["M"]}}, "categories":[{"code":"N", "upos":"NOUN"}]}`, i: "class"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.i, func(t *testing.T) {
+			_, err := Parse([]byte(tt.v))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestToUD(t *testing.T) {
+	tests := []struct {
+		v     string
+		upos  string
+		feats string
+	}{
+		{v: "", upos: "", feats: ""},
+		{v: "Ncfsnn-", upos: "NOUN", feats: "Case=Nom|Gender=Fem|Number=Sing"},
+		{v: "Npmsgn-", upos: "PROPN", feats: "Case=Gen|Gender=Masc|Number=Sing"},
+		{v: "Vgmp3---n--ni-", upos: "VERB", feats: "Aspect=Imp|Mood=Ind|Person=3|Polarity=Pos|Tense=Pres|VerbForm=Fin"},
+		{v: "Vgms3---n--ni-", upos: "VERB", feats: "Aspect=Imp|Mood=Ind|Person=3|Polarity=Pos|Tense=Past|VerbForm=Fin"},
+		{v: "Sgi", upos: "ADP", feats: "Case=Ins"},
+		{v: "M----d-", upos: "NUM", feats: "NumForm=Digit"},
+		{v: "M----rn", upos: "NUM", feats: "Definite=Ind|NumForm=Roman"},
+		{v: "Cs", upos: "SCONJ", feats: ""},
+		{v: "Ig", upos: "INTJ", feats: ""},
+		{v: "T.", upos: "PUNCT", feats: ""},
+		{v: "Y", upos: "X", feats: "Abbr=Yes"},
+		{v: "X-", upos: "X", feats: ""},
+		{v: "Z", upos: "X", feats: ""},
+		{v: "Ncfsnnzzzz", upos: "NOUN", feats: "Case=Nom|Gender=Fem|Number=Sing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v, func(t *testing.T) {
+			upos, feats := Default().ToUD(tt.v)
+			assert.Equal(t, tt.upos, upos)
+			assert.Equal(t, tt.feats, feats)
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	f := Default().Decode("Ncfsin-")
+	assert.Equal(t, &Feature{Code: "N", EN: "noun", LT: "daiktavardis"}, f.POS)
+	assert.Equal(t, map[string]Feature{
+		"type":      {Code: "c", EN: "common", LT: "bendrinis"},
+		"gender":    {Code: "f", EN: "feminine", LT: "moteriškoji"},
+		"number":    {Code: "s", EN: "singular", LT: "vienaskaita"},
+		"case":      {Code: "i", EN: "instrumental", LT: "įnagininkas"},
+		"reflexive": {Code: "n", EN: "non-reflexive", LT: "nesangrąžinis"},
+	}, f.Attributes)
+	assert.Nil(t, f.Unknown)
+}
+
+func TestDecode_Any(t *testing.T) {
+	f := Default().Decode("T.")
+	assert.Equal(t, "punctuation", f.POS.EN)
+	assert.Equal(t, map[string]Feature{"type": {Code: "."}}, f.Attributes)
+	assert.Nil(t, f.Unknown)
+}
+
+func TestDecode_Unknown(t *testing.T) {
+	f := Default().Decode("Nczsnn-a")
+	assert.Equal(t, "noun", f.POS.EN)
+	assert.Equal(t, []UnknownFeature{{Position: 2, Code: "z"}, {Position: 7, Code: "a"}}, f.Unknown)
+	assert.Equal(t, 4, len(f.Attributes))
+
+	f = Default().Decode("Z-")
+	assert.Nil(t, f.POS)
+	assert.Equal(t, []UnknownFeature{{Position: 0, Code: "Z"}}, f.Unknown)
+
+	assert.Equal(t, &Features{}, Default().Decode(""))
+}
+
+func TestDefault_AllValuesLabeled(t *testing.T) {
+	for _, c := range Default().Categories {
+		assert.NotEmpty(t, c.LT, c.Code)
+		for _, a := range c.Attributes {
+			for k, v := range a.Values {
+				assert.NotEmpty(t, v.EN, "%s.%s.%s", c.Code, a.Name, k)
+				assert.NotEmpty(t, v.LT, "%s.%s.%s", c.Code, a.Name, k)
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "tagset.json")
+	assert.Nil(t, os.WriteFile(f, defaultData, 0644))
+	ts, err := Load(f)
+	assert.Nil(t, err)
+	assert.NotNil(t, ts)
+	_, err = Load(f + ".missing")
+	assert.NotNil(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		v string
+		e bool
+	}{
+		{v: "Ncfsnn-", e: true},
+		{v: "Vgmp3---n--ni-", e: true},
+		{v: "M----d-", e: true},
+		{v: "T.", e: true},
+		{v: "X-", e: true},
+		{v: "", e: false},
+		{v: "Z", e: false},
+		{v: "Nzfsnn-", e: false},
+		{v: "Ncfsnn-a", e: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.v, func(t *testing.T) {
+			assert.Equal(t, tt.e, Default().Validate(tt.v))
+		})
+	}
+}
+
+func TestClasses(t *testing.T) {
+	ts := Default()
+	assert.True(t, ts.IsSeparator("T."))
+	assert.True(t, ts.IsSeparator("Th"))
+	assert.False(t, ts.IsSeparator("Ncfsnn-"))
+	assert.True(t, ts.IsNumber("M----d-"))
+	assert.True(t, ts.IsNumber("M----rn"))
+	assert.False(t, ts.IsNumber("Th"))
+	assert.True(t, ts.IsNumberCandidate("Th"))
+	assert.True(t, ts.IsNumberCandidate("X-"))
+	assert.False(t, ts.IsNumberCandidate("M----d-"))
+	assert.Equal(t, "M----d-", ts.NumberMi("Th"))
+	assert.Equal(t, "M----rn", ts.NumberMi("M----rn"))
+}
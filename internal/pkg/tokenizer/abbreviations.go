@@ -0,0 +1,18 @@
+package tokenizer
+
+// lower case Lithuanian abbreviations written with a dot at the end
+var abbreviations = makeSet([]string{
+	"a", "al", "angl", "apyl", "aps", "asist", "aut", "bendr", "d", "dgs", "doc", "dr", "dėst", "el", "ev", "g",
+	"gen", "gerb", "gim", "habil", "įm", "jaun", "k", "kl", "kpt", "kt", "kun", "lat", "liet", "lot", "ltn", "m",
+	"mėn", "min", "mir", "mjr", "mln", "mlrd", "mok", "nr", "p", "pan", "pl", "plg", "plk", "pr", "pranc", "prof",
+	"proc", "psl", "pvz", "r", "raj", "red", "rus", "sav", "sek", "sen", "sk", "str", "šv", "tel", "tūkst", "vad",
+	"val", "vns", "vnt", "vok", "vs", "vysk", "vyr", "ž", "žr",
+})
+
+func makeSet(values []string) map[string]bool {
+	res := make(map[string]bool, len(values))
+	for _, v := range values {
+		res[v] = true
+	}
+	return res
+}
@@ -0,0 +1,219 @@
+package tokenizer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"mvdan.cc/xurls/v2"
+)
+
+const (
+	sentenceEnds  = ".!?…"
+	closingQuotes = "\"'»“”)]}"
+	numberSigns   = "-+−"
+	numberSeps    = ".,"
+)
+
+var (
+	urlRegexp       *regexp.Regexp
+	urlPrefixRegexp *regexp.Regexp
+)
+
+func init() {
+	urlRegexp = xurls.Relaxed()
+	urlPrefixRegexp = regexp.MustCompile(`(?i)^([a-z][a-z0-9+.\-]*://|www\.|mailto:)`)
+}
+
+//Segmenter splits Lithuanian text into tokens, sentences and paragraphs without lex
+type Segmenter struct{}
+
+//NewSegmenter creates a local segmenter
+func NewSegmenter() *Segmenter {
+	return &Segmenter{}
+}
+
+//Process segments the text
+func (s *Segmenter) Process(ctx context.Context, text string) (*api.SegmenterResult, error) {
+	return Segment(text), nil
+}
+
+type token struct {
+	from, to int
+	// abbr is set for abbreviations and initials ending with a dot
+	abbr bool
+	// newLine is set if there is a new line before the token
+	newLine bool
+}
+
+//Segment returns tokens, sentences and paragraphs of the text as lex does.
+//Tokens are words, numbers, URLs, abbreviations with the dot and single symbols, spaces are not included.
+//A new line ends the paragraph
+func Segment(text string) *api.SegmenterResult {
+	rns := []rune(text)
+	tokens := tokenize(rns, urlStarts(text, rns))
+	res := &api.SegmenterResult{Seg: [][]int{}, S: [][]int{}, P: [][]int{}}
+	sf, pf := -1, -1
+	for i, t := range tokens {
+		res.Seg = append(res.Seg, []int{t.from, t.to - t.from})
+		if sf < 0 {
+			sf = t.from
+		}
+		if pf < 0 {
+			pf = t.from
+		}
+		last := i == len(tokens)-1
+		if last || tokens[i+1].newLine || isSentenceEnd(rns, tokens, i) {
+			res.S = append(res.S, []int{sf, t.to - sf})
+			sf = -1
+		}
+		if last || tokens[i+1].newLine {
+			res.P = append(res.P, []int{pf, t.to - pf})
+			pf = -1
+		}
+	}
+	return res
+}
+
+func tokenize(rns []rune, urls map[int]int) []token {
+	res := make([]token, 0)
+	newLine := false
+	for i := 0; i < len(rns); {
+		r := rns[i]
+		if unicode.IsSpace(r) {
+			newLine = newLine || r == '\n'
+			i++
+			continue
+		}
+		t := token{from: i, newLine: newLine}
+		newLine = false
+		if to, ok := urls[i]; ok {
+			t.to = to
+		} else if isNumberStart(rns, i) {
+			t.to = numberEnd(rns, i+1)
+		} else if isWordRune(r) {
+			t.to, t.abbr = wordEnd(rns, i)
+		} else if r == '.' {
+			t.to = runEnd(rns, i, '.')
+		} else {
+			t.to = i + 1
+		}
+		res = append(res, t)
+		i = t.to
+	}
+	return res
+}
+
+//urlStarts returns URL ends by the start rune position. The relaxed matches like A.Sm in A.Smetona are dropped:
+//a URL must start at a token boundary and either end at a token boundary or start with a scheme or www.
+func urlStarts(text string, rns []rune) map[int]int {
+	res := make(map[int]int)
+	for _, m := range urlRegexp.FindAllStringIndex(text, -1) {
+		from := len([]rune(text[:m[0]]))
+		to := from + len([]rune(text[m[0]:m[1]]))
+		if from > 0 && isWordRune(rns[from-1]) {
+			continue
+		}
+		if to < len(rns) && isWordRune(rns[to]) && !urlPrefixRegexp.MatchString(text[m[0]:m[1]]) {
+			continue
+		}
+		res[from] = to
+	}
+	return res
+}
+
+func isNumberStart(rns []rune, i int) bool {
+	if unicode.IsDigit(rns[i]) {
+		return i == 0 || !isWordRune(rns[i-1])
+	}
+	return strings.ContainsRune(numberSigns, rns[i]) && i+1 < len(rns) && unicode.IsDigit(rns[i+1]) &&
+		(i == 0 || unicode.IsSpace(rns[i-1]) || strings.ContainsRune("([", rns[i-1]))
+}
+
+func numberEnd(rns []rune, i int) int {
+	for i < len(rns) {
+		if unicode.IsDigit(rns[i]) {
+			i++
+		} else if strings.ContainsRune(numberSeps, rns[i]) && i+1 < len(rns) && unicode.IsDigit(rns[i+1]) {
+			i += 2
+		} else {
+			break
+		}
+	}
+	// a number followed by letters is a word, e.g. 5G
+	if i < len(rns) && unicode.IsLetter(rns[i]) {
+		e, _ := wordEnd(rns, i)
+		return e
+	}
+	return i
+}
+
+//wordEnd returns the end of the word. The dot is included for abbreviations and initials
+func wordEnd(rns []rune, i int) (int, bool) {
+	if e := dottedAbbreviationEnd(rns, i); e > 0 {
+		return e, true
+	}
+	e := i
+	for e < len(rns) && isWordRune(rns[e]) {
+		e++
+	}
+	if e < len(rns) && rns[e] == '.' {
+		w := rns[i:e]
+		if (len(w) == 1 && unicode.IsUpper(w[0])) || abbreviations[strings.ToLower(string(w))] {
+			return e + 1, true
+		}
+	}
+	return e, false
+}
+
+//dottedAbbreviationEnd returns the end of abbreviations like t.y., a.a. or -1
+func dottedAbbreviationEnd(rns []rune, i int) int {
+	parts, e := 0, i
+	for e+1 < len(rns) && unicode.IsLetter(rns[e]) && rns[e+1] == '.' && (e == i || !isWordRune(rns[e-1]) || rns[e-1] == '.') {
+		parts++
+		e += 2
+	}
+	if parts > 1 {
+		return e
+	}
+	return -1
+}
+
+func runEnd(rns []rune, i int, r rune) int {
+	for i < len(rns) && rns[i] == r {
+		i++
+	}
+	return i
+}
+
+//isSentenceEnd checks if the sentence ends at the token i.
+//The sentence ends after the final punctuation and the closing quotes if the next word does not start with a lower case letter
+func isSentenceEnd(rns []rune, tokens []token, i int) bool {
+	if !isFinal(rns, tokens, i) {
+		return false
+	}
+	if i+1 == len(tokens) {
+		return true
+	}
+	t, n := tokens[i], tokens[i+1]
+	return n.from > t.to && !unicode.IsLower(rns[n.from])
+}
+
+//isFinal checks if the token is the final punctuation or a closing quote just after it
+func isFinal(rns []rune, tokens []token, i int) bool {
+	t := tokens[i]
+	if t.abbr {
+		return false
+	}
+	if strings.ContainsRune(sentenceEnds, rns[t.to-1]) {
+		return true
+	}
+	return strings.ContainsRune(closingQuotes, rns[t.from]) && i > 0 && tokens[i-1].to == t.from &&
+		isFinal(rns, tokens, i-1)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
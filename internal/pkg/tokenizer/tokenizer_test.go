@@ -0,0 +1,97 @@
+package tokenizer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegment_Tokens(t *testing.T) {
+	tests := []struct {
+		text string
+		exp  []string
+	}{
+		{text: "Mama su kasa kasa smėlį.", exp: []string{"Mama", "su", "kasa", "kasa", "smėlį", "."}},
+		{text: "  olia  ", exp: []string{"olia"}},
+		{text: "a", exp: []string{"a"}},
+		{text: "", exp: []string{}},
+		{text: "Vilniaus-Kauno kelias", exp: []string{"Vilniaus", "-", "Kauno", "kelias"}},
+		{text: "Kaina 10,5 Eur, -10 ir 1.000.000", exp: []string{"Kaina", "10,5", "Eur", ",", "-10", "ir", "1.000.000"}},
+		{text: "nuo 2-3", exp: []string{"nuo", "2", "-", "3"}},
+		{text: "5G ryšys", exp: []string{"5G", "ryšys"}},
+		{text: "Žr. https://www.delfi.lt/news?id=1, pvz. ten", exp: []string{"Žr.", "https://www.delfi.lt/news?id=1", ",",
+			"pvz.", "ten"}},
+		{text: "t.y. ir a.a. tėvas", exp: []string{"t.y.", "ir", "a.a.", "tėvas"}},
+		{text: "A. Vaičiūnas", exp: []string{"A.", "Vaičiūnas"}},
+		{text: "„Labas“, (tarė) jis...", exp: []string{"„", "Labas", "“", ",", "(", "tarė", ")", "jis", "..."}},
+		{text: "2020 m. sausio 1 d.", exp: []string{"2020", "m.", "sausio", "1", "d."}},
+		{text: "A.Smetona", exp: []string{"A.", "Smetona"}},
+		{text: "m.lapkričio", exp: []string{"m.", "lapkričio"}},
+		{text: "Kaunas.Vilnius", exp: []string{"Kaunas", ".", "Vilnius"}},
+		{text: "žr.psl.", exp: []string{"žr.", "psl."}},
+		{text: "Žr. delfi.lt.", exp: []string{"Žr.", "delfi.lt", "."}},
+		{text: "www.delfi.lt puslapis", exp: []string{"www.delfi.lt", "puslapis"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			r := Segment(tc.text)
+			assert.Equal(t, tc.exp, strs(tc.text, r.Seg))
+		})
+	}
+}
+
+func TestSegment_Sentences(t *testing.T) {
+	tests := []struct {
+		text string
+		exp  []string
+	}{
+		{text: "Labas. Kaip sekasi?", exp: []string{"Labas.", "Kaip sekasi?"}},
+		{text: "Labas! kaip sekasi", exp: []string{"Labas! kaip sekasi"}},
+		{text: "Tai A. Vaičiūnas. Jis čia.", exp: []string{"Tai A. Vaičiūnas.", "Jis čia."}},
+		{text: "Obuoliai, kriaušės ir kt. Viskas.", exp: []string{"Obuoliai, kriaušės ir kt. Viskas."}},
+		{text: "Jis tarė: „Eik.“ Aš ėjau.", exp: []string{"Jis tarė: „Eik.“", "Aš ėjau."}},
+		{text: "Ką?! Nežinau...", exp: []string{"Ką?!", "Nežinau..."}},
+		{text: "Pirma eilutė\nantra eilutė", exp: []string{"Pirma eilutė", "antra eilutė"}},
+		{text: "Kaina 10.5 Eur. Gerai.", exp: []string{"Kaina 10.5 Eur.", "Gerai."}},
+		{text: "Žiūrėk www.lrt.lt. Ten.", exp: []string{"Žiūrėk www.lrt.lt.", "Ten."}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.text, func(t *testing.T) {
+			r := Segment(tc.text)
+			assert.Equal(t, tc.exp, strs(tc.text, r.S))
+		})
+	}
+}
+
+func TestSegment_Paragraphs(t *testing.T) {
+	text := "Pirmas. Sakinys.\n\n  Antras\r\nTrečias "
+	r := Segment(text)
+	assert.Equal(t, []string{"Pirmas. Sakinys.", "Antras", "Trečias"}, strs(text, r.P))
+	assert.Equal(t, []string{"Pirmas.", "Sakinys.", "Antras", "Trečias"}, strs(text, r.S))
+}
+
+func TestSegment_Offsets(t *testing.T) {
+	r := Segment("ąž ėė.")
+	assert.Equal(t, &api.SegmenterResult{Seg: [][]int{{0, 2}, {3, 2}, {5, 1}}, S: [][]int{{0, 6}},
+		P: [][]int{{0, 6}}}, r)
+}
+
+func TestProcess(t *testing.T) {
+	r, err := NewSegmenter().Process(context.Background(), "Labas.")
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 5}, {5, 1}}, r.Seg)
+}
+
+func strs(text string, spans [][]int) []string {
+	rns := []rune(text)
+	res := make([]string, 0, len(spans))
+	for _, s := range spans {
+		res = append(res, string(rns[s[0]:s[0]+s[1]]))
+	}
+	return res
+}
@@ -4,3 +4,14 @@ import "errors"
 
 //ErrTooBusy indicates too many request to service
 var ErrTooBusy error = errors.New("too busy")
+
+//ErrBackendUnavailable indicates the backend is known to be down, the call is not made
+var ErrBackendUnavailable error = errors.New("backend unavailable")
+
+//ErrBackendFailed indicates the backend failed with connection errors or 5xx responses after all retries
+var ErrBackendFailed error = errors.New("backend failed")
+
+//IsBackendDown checks if err is ErrBackendUnavailable or wraps ErrBackendFailed
+func IsBackendDown(err error) bool {
+	return errors.Is(err, ErrBackendUnavailable) || errors.Is(err, ErrBackendFailed)
+}
@@ -2,15 +2,9 @@ package utils
 
 import (
 	"math/rand"
-	"net/http"
 	"time"
 )
 
-//IsRetryCode checks if http code indicates retryable error
-func IsRetryCode(status int) bool {
-	return status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable
-}
-
 var (
 	closedChan chan time.Time
 	//ExpBackoffList list of backoff values for http retry delays
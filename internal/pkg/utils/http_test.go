@@ -4,29 +4,6 @@ import (
 	"testing"
 )
 
-func TestIsRetryCode(t *testing.T) {
-	type args struct {
-		status int
-	}
-	tests := []struct {
-		name string
-		args args
-		want bool
-	}{
-		{name: "429", args: args{status: 429}, want: true},
-		{name: "503", args: args{status: 503}, want: true},
-		{name: "200", args: args{status: 200}, want: false},
-		{name: "400", args: args{status: 400}, want: false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := IsRetryCode(tt.args.status); got != tt.want {
-				t.Errorf("IsRetryCode() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
 func Test_randNum(t *testing.T) {
 	type args struct {
 		st int
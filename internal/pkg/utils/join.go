@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/pkg/errors"
+)
+
+//TextSeparator separates the joined texts, the texts become separate paragraphs
+const TextSeparator = "\n\n"
+
+//TextSeparatorLen is the length of TextSeparator in runes
+var TextSeparatorLen = len([]rune(TextSeparator))
+
+//JoinTexts joins the texts with TextSeparator, returns the rune offsets of the texts in the joined one
+func JoinTexts(texts []string) (string, []int) {
+	sb := strings.Builder{}
+	offsets := make([]int, len(texts))
+	pos := 0
+	for i, t := range texts {
+		if i > 0 {
+			sb.WriteString(TextSeparator)
+			pos += TextSeparatorLen
+		}
+		offsets[i] = pos
+		sb.WriteString(t)
+		pos += len([]rune(t))
+	}
+	return sb.String(), offsets
+}
+
+//JoinSegments joins the segmentation results of the texts joined by JoinTexts
+func JoinSegments(data []*api.SegmenterResult, offsets []int) *api.SegmenterResult {
+	res := &api.SegmenterResult{}
+	for i, d := range data {
+		res.Seg = append(res.Seg, ShiftSpans(d.Seg, offsets[i])...)
+		res.S = append(res.S, ShiftSpans(d.S, offsets[i])...)
+		res.P = append(res.P, ShiftSpans(d.P, offsets[i])...)
+	}
+	return res
+}
+
+//CutAnalysis returns the analysis of the [from, to) part of the joined text shifted to from.
+//Only the segments inside the part are kept with their tags, an.Tags may be nil
+func CutAnalysis(an *api.Analysis, from, to int) (*api.Analysis, error) {
+	sgm := an.Segments
+	if an.Tags != nil && len(an.Tags.Msd) != len(sgm.Seg) {
+		return nil, errors.Errorf("wrong tagger result: %d msd for %d segments", len(an.Tags.Msd), len(sgm.Seg))
+	}
+	res := &api.Analysis{Segments: &api.SegmenterResult{Seg: make([][]int, 0), S: CutSpans(sgm.S, from, to),
+		P: CutSpans(sgm.P, from, to)}}
+	withStem := false
+	if an.Tags != nil {
+		res.Tags = &api.TaggerResult{Msd: make([][][]string, 0)}
+		withStem = len(an.Tags.Stem) == len(an.Tags.Msd)
+	}
+	for i, s := range sgm.Seg {
+		if len(s) < 2 || s[0] < from || s[0]+s[1] > to {
+			continue
+		}
+		res.Segments.Seg = append(res.Segments.Seg, []int{s[0] - from, s[1]})
+		if res.Tags != nil {
+			res.Tags.Msd = append(res.Tags.Msd, an.Tags.Msd[i])
+			if withStem {
+				res.Tags.Stem = append(res.Tags.Stem, an.Tags.Stem[i])
+			}
+		}
+	}
+	return res, nil
+}
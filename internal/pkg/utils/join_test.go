@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/airenas/lt-pos-tagger/internal/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCutAnalysis(t *testing.T) {
+	an := &api.Analysis{Segments: &api.SegmenterResult{Seg: [][]int{{0, 2}, {3, 1}, {6, 3}}, S: [][]int{{0, 4}, {6, 3}},
+		P: [][]int{{0, 4}, {6, 3}}},
+		Tags: &api.TaggerResult{Msd: [][][]string{{{"a"}}, {{"b"}}, {{"c"}}}, Stem: []string{"a", "b", "c"}}}
+	r, err := CutAnalysis(an, 6, 9)
+	require.Nil(t, err)
+	assert.Equal(t, &api.Analysis{Segments: &api.SegmenterResult{Seg: [][]int{{0, 3}}, S: [][]int{{0, 3}}, P: [][]int{{0, 3}}},
+		Tags: &api.TaggerResult{Msd: [][][]string{{{"c"}}}, Stem: []string{"c"}}}, r)
+	r, err = CutAnalysis(&api.Analysis{Segments: an.Segments}, 0, 4)
+	require.Nil(t, err)
+	assert.Equal(t, [][]int{{0, 2}, {3, 1}}, r.Segments.Seg)
+	assert.Nil(t, r.Tags)
+	_, err = CutAnalysis(&api.Analysis{Segments: an.Segments, Tags: &api.TaggerResult{}}, 0, 4)
+	assert.NotNil(t, err)
+}
+func TestJoinTexts(t *testing.T) {
+	text, offsets := JoinTexts([]string{"ąž", "b", "cc"})
+	assert.Equal(t, "ąž\n\nb\n\ncc", text)
+	assert.Equal(t, []int{0, 4, 7}, offsets)
+}
+
+func TestJoinSegments(t *testing.T) {
+	r := JoinSegments([]*api.SegmenterResult{{Seg: [][]int{{0, 2}}, S: [][]int{{0, 2}}, P: [][]int{{0, 2}}},
+		{Seg: [][]int{{0, 1}}, S: [][]int{{0, 1}}}}, []int{0, 4})
+	assert.Equal(t, &api.SegmenterResult{Seg: [][]int{{0, 2}, {4, 1}}, S: [][]int{{0, 2}, {4, 1}}, P: [][]int{{0, 2}}}, r)
+}
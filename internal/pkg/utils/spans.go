@@ -0,0 +1,35 @@
+package utils
+
+//ShiftSpans returns a copy of [offset, length] spans with the offset moved by
+func ShiftSpans(spans [][]int, by int) [][]int {
+	res := make([][]int, 0, len(spans))
+	for _, s := range spans {
+		ns := append([]int{}, s...)
+		if len(ns) > 0 {
+			ns[0] += by
+		}
+		res = append(res, ns)
+	}
+	return res
+}
+
+//CutSpans returns the parts of [offset, length] spans in [from, to) shifted to from
+func CutSpans(spans [][]int, from, to int) [][]int {
+	res := make([][]int, 0)
+	for _, s := range spans {
+		if len(s) < 2 {
+			continue
+		}
+		st, e := s[0], s[0]+s[1]
+		if st < from {
+			st = from
+		}
+		if e > to {
+			e = to
+		}
+		if st < e {
+			res = append(res, []int{st - from, e - st})
+		}
+	}
+	return res
+}
@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShiftSpans(t *testing.T) {
+	in := [][]int{{0, 2}, {3, 2}}
+	assert.Equal(t, [][]int{{10, 2}, {13, 2}}, ShiftSpans(in, 10))
+	assert.Equal(t, [][]int{{0, 2}, {3, 2}}, in)
+	assert.Equal(t, [][]int{}, ShiftSpans(nil, 10))
+}
+
+func TestCutSpans(t *testing.T) {
+	assert.Equal(t, [][]int{{0, 2}, {3, 1}}, CutSpans([][]int{{0, 2}, {3, 2}, {6, 2}}, 0, 4))
+	assert.Equal(t, [][]int{{0, 2}}, CutSpans([][]int{{0, 2}, {3, 2}, {6, 2}}, 3, 5))
+	assert.Equal(t, [][]int{{0, 1}}, CutSpans([][]int{{0, 5}}, 4, 7))
+	assert.Equal(t, [][]int{}, CutSpans([][]int{{0, 2}}, 3, 5))
+}